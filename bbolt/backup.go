@@ -0,0 +1,114 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat names auto-backups so name order is time order
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// Backup writes a consistent snapshot of the whole database to w while it stays online
+func (s *Store) Backup(w io.Writer) (n int64, err error) {
+	err = s.View(func(tx *Tx) error {
+		n, err = tx.tx.WriteTo(w)
+		return err
+	})
+	return
+}
+
+// StartAutoBackup writes a Backup to a timestamped file in dir every interval and removes
+// all but the newest keep of them, zero keeps every one. It runs until stop is called or the
+// store is closed, failures are reported to the store Logger and retried on the next tick.
+// stop returns once a backup in progress has finished, so dir may be removed right after.
+// An interval that is not positive returns ErrInvalidArgument.
+func (s *Store) StartAutoBackup(dir string, interval time.Duration, keep int) (stop func(), err error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("%w: backup interval %v", ErrInvalidArgument, interval)
+	}
+	if err = os.MkdirAll(dir, s.mode|(s.mode&0444)>>2); err != nil {
+		return nil, err
+	}
+	done, exited := make(chan struct{}), make(chan struct{})
+	backup := func() {
+		defer close(exited)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-s.done:
+				return
+			case <-ticker.C:
+				if err := s.backupTo(dir, keep); err != nil {
+					s.logger.Printf("kvass: auto-backup: %v", err)
+				}
+			}
+		}
+	}
+	if !s.goBackground(backup) {
+		return nil, ErrClosed
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-exited
+	}, nil
+}
+
+// backupTo writes one backup into dir and prunes the ones beyond keep
+func (s *Store) backupTo(dir string, keep int) error {
+	prefix := filepath.Base(s.path) + "."
+	name := filepath.Join(dir, prefix+time.Now().UTC().Format(backupTimeFormat)+".bak")
+
+	// a partial file is never mistaken for a backup
+	tmp := name + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, s.mode)
+	if err != nil {
+		return err
+	}
+	_, err = s.Backup(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if keep <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var backups []string
+	for _, e := range entries {
+		if n := e.Name(); strings.HasPrefix(n, prefix) && strings.HasSuffix(n, ".bak") {
+			backups = append(backups, n)
+		}
+	}
+	sort.Strings(backups)
+	for len(backups) > keep {
+		if err = os.Remove(filepath.Join(dir, backups[0])); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
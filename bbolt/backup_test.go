@@ -0,0 +1,123 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func backupFiles(t *testing.T, dir string) (names []string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".bak") {
+			names = append(names, e.Name())
+		}
+	}
+	return
+}
+
+func TestStartAutoBackup(t *testing.T) {
+	s := newTestStore(t)
+	mustSave(t, s, "b", "k", "v")
+	dir := filepath.Join(t.TempDir(), "backups")
+	stop, err := s.StartAutoBackup(dir, 5*time.Millisecond, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// wait until a third backup has pushed out the first so pruning has run
+	var first string
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		names := backupFiles(t, dir)
+		if first == "" && len(names) > 0 {
+			first = names[0]
+		}
+		if len(names) > 0 && names[0] != first {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no backup was pruned, have %q", names)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+
+	// stop waited for a backup in flight, no temporary file is left behind
+	names := backupFiles(t, dir)
+	if len(names) != 2 {
+		t.Fatalf("%d backups after pruning, want 2: %q", len(names), names)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != len(names) {
+		t.Fatalf("dir holds %d entries after stop, %v, want only the %d backups", len(entries), err, len(names))
+	}
+	restored, err := NewStore(filepath.Join(dir, names[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, err := restored.Get([]byte("b"), []byte("k")); err != nil || string(val) != "v" {
+		t.Fatalf("Get from backup = %q, %v", val, err)
+	}
+	if err := restored.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStartAutoBackupInvalidInterval(t *testing.T) {
+	s := newTestStore(t)
+	dir := filepath.Join(t.TempDir(), "backups")
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if _, err := s.StartAutoBackup(dir, interval, 1); !errors.Is(err, ErrInvalidArgument) {
+			t.Fatalf("StartAutoBackup(%v) = %v, want ErrInvalidArgument", interval, err)
+		}
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("dir created for an invalid interval: %v", err)
+	}
+}
+
+func TestBackup(t *testing.T) {
+	s := newTestStore(t)
+	for i := 0; i < 50; i++ {
+		mustSave(t, s, "b", fmt.Sprintf("k%02d", i), fmt.Sprintf("v%02d", i))
+	}
+	path := filepath.Join(t.TempDir(), "backup.db")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Backup(f); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	var want, got bytes.Buffer
+	if _, err := s.Export([]byte("b"), &want); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restored.Export([]byte("b"), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want.Bytes(), got.Bytes()) {
+		t.Fatal("restored backup differs from the store")
+	}
+}
@@ -2,154 +2,1503 @@ package db
 
 import (
 	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/sha256"
 	"encoding/binary"
 	"errors"
-	"io"
-	"log"
+	"fmt"
+	"io/fs"
 	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 )
 
 var (
-	ErrNotfound = errors.New("key not found in store")
+	ErrNotfound        = errors.New("key not found in store")
+	ErrBucketNotFound  = errors.New("bucket not found in store")
+	ErrBucketExists    = errors.New("bucket already exists in store")
+	ErrInvalidNumber   = errors.New("value is not a number")
+	ErrReadOnly        = errors.New("store is read-only")
+	ErrClosed          = errors.New("store is closed")
+	ErrEmptyBucket     = errors.New("bucket name is empty")
+	ErrEmptyKey        = errors.New("key is empty")
+	ErrInvalidArgument = errors.New("invalid argument")
+
+	// open failures, the bbolt error is kept in the message
+	ErrLocked      = errors.New("database file is locked by another process")
+	ErrCorrupt     = errors.New("database file is corrupt")
+	ErrPermission  = errors.New("permission denied opening database file")
+	ErrAlreadyOpen = errors.New("database file is already open in this process with other options")
 )
 
-// Store wrap for bbolt
+// scanCheckInterval is how many keys ScanContext visits between context checks
+const scanCheckInterval = 256
+
+// KV is a key and val pair
+type KV struct {
+	Key []byte `json:"key"`
+	Val []byte `json:"value"`
+}
+
+// WriteOp is a save of Val to Key in Bucket, or a delete of Key when Delete is set
+type WriteOp struct {
+	Bucket, Key, Val []byte
+	Delete           bool
+}
+
+// Store wrap for bbolt.
+// Keys and vals it returns or passes to callbacks are copies, so they may be
+// retained or handed to other goroutines after the transaction has ended.
+//
+// A Store is safe for concurrent use by multiple goroutines. bbolt runs one write
+// transaction at a time and any number of read transactions alongside it, so writers
+// queue behind each other while readers do not wait. Callbacks such as the ObserverFunc,
+// SaveHook and Logger may be called from several goroutines at once.
+// Values bound to one transaction, Tx, Reader and BucketView, are not safe to share.
 type Store struct {
-	db *bolt.DB
+	db         *bolt.DB
+	path       string
+	mode       os.FileMode
+	mkdir      bool
+	opts       *bolt.Options
+	allocSize  int
+	compressor Compressor
+	aead       cipher.AEAD
+	logger     Logger
+	observer   ObserverFunc
+	checksum   bool
+	onSave     []SaveHook
+	maxValSize int
+	keyID      [sha256.Size]byte
+
+	mu       sync.Mutex
+	watchers map[string][]chan Event
+
+	// swap is held for reading around every use of db and for writing while CompactInPlace
+	// or Close replace or close it
+	swap sync.RWMutex
+
+	// done is closed by Close to stop background goroutines, wg waits for them
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+
+	// guarded by registry.mu
+	key  string
+	refs int
+}
+
+// NewStore returns new store.
+// Opening a path that is already open in this process returns the same *Store and the
+// file is closed once every caller has called Close. As the callers share one *Store, each
+// must call Close exactly once, a second Close drops the reference of another caller.
+// The second open fails with ErrAlreadyOpen unless its read-only mode, compression,
+// encryption key, checksum and max value size match the open store, and it may not add
+// a logger, observer or hooks.
+func NewStore(dbName string, opts ...Option) (*Store, error) {
+	s := &Store{path: dbName, mode: 0600, logger: nopLogger{}, done: make(chan struct{})}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := filepath.Abs(dbName)
+	if err != nil {
+		return nil, err
+	}
+	registry.mu.Lock()
+	for {
+		if open := registry.stores[key]; open != nil {
+			defer registry.mu.Unlock()
+			if !open.shareable(s) {
+				return nil, ErrAlreadyOpen
+			}
+			open.refs++
+			return open, nil
+		}
+		wait, ok := registry.opening[key]
+		if !ok {
+			break
+		}
+		registry.mu.Unlock()
+		<-wait
+		registry.mu.Lock()
+	}
+	wait := make(chan struct{})
+	registry.opening[key] = wait
+	registry.mu.Unlock()
+
+	db, err := s.open()
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	delete(registry.opening, key)
+	close(wait)
+	if err != nil {
+		return nil, err
+	}
+	s.db = db
+	s.key, s.refs = key, 1
+	registry.stores[key] = s
+	return s, nil
+}
+
+// open creates the directories when asked and opens the bbolt file
+func (s *Store) open() (*bolt.DB, error) {
+	if s.mkdir {
+		// directories need x wherever the file mode grants r
+		if err := os.MkdirAll(filepath.Dir(s.path), s.mode|(s.mode&0444)>>2); err != nil {
+			return nil, openError(err)
+		}
+	}
+	db, err := bolt.Open(s.path, s.mode, s.opts)
+	if err != nil {
+		return nil, openError(err)
+	}
+	if s.allocSize > 0 {
+		db.AllocSize = s.allocSize
+	}
+	return db, nil
 }
 
-// NewStore returns new store
-func NewStore(dbName string) *Store {
-	db, err := bolt.Open(dbName, 0600, nil)
+// NewStoreWithRetry returns new store, trying up to attempts times while another process holds
+// the file lock. Each attempt waits backoff for the lock unless opts set a bbolt Timeout,
+// then sleeps backoff before the next one. The last error is returned when all attempts fail,
+// attempts below 1 return ErrInvalidArgument.
+func NewStoreWithRetry(dbName string, attempts int, backoff time.Duration, opts ...Option) (s *Store, err error) {
+	if attempts < 1 {
+		return nil, fmt.Errorf("%w: %d attempts", ErrInvalidArgument, attempts)
+	}
+	opts = append(opts, func(s *Store) error {
+		if o := s.boltOptions(); o.Timeout == 0 {
+			o.Timeout = backoff
+		}
+		return nil
+	})
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(backoff)
+		}
+		if s, err = NewStore(dbName, opts...); !errors.Is(err, ErrLocked) {
+			return
+		}
+	}
+	return
+}
+
+// NewStoreWithOptions returns new store opened with bbolt options, nil uses the defaults
+func NewStoreWithOptions(dbName string, opts *bolt.Options) (*Store, error) {
+	return NewStore(dbName, WithBoltOptions(opts))
+}
+
+// NewStoreWithCompression returns new store that compresses vals with codec
+func NewStoreWithCompression(dbName string, codec Compressor) (*Store, error) {
+	return NewStore(dbName, WithCompression(codec))
+}
+
+// NewReadOnlyStore returns new store that never writes to dbName, mutating methods fail with ErrReadOnly
+func NewReadOnlyStore(dbName string, opts ...Option) (*Store, error) {
+	return NewStore(dbName, append(opts, func(s *Store) error {
+		s.boltOptions().ReadOnly = true
+		return nil
+	})...)
+}
+
+// NewTempStore returns new store on a uniquely named temporary file,
+// cleanup closes the store and removes the file
+func NewTempStore(opts ...Option) (s *Store, cleanup func(), err error) {
+	f, err := os.CreateTemp("", "kvass-*.db")
+	if err != nil {
+		return nil, nil, err
+	}
+	path := f.Name()
+	_ = f.Close()
+
+	if s, err = NewStore(path, opts...); err != nil {
+		_ = os.Remove(path)
+		return nil, nil, err
+	}
+	return s, func() {
+		_ = s.Close()
+		_ = os.Remove(path)
+	}, nil
+}
+
+// MustNewStore is like NewStore but panics if the store cannot be opened
+func MustNewStore(dbName string, opts ...Option) *Store {
+	s, err := NewStore(dbName, opts...)
 	if err != nil {
-		log.Fatal(err)
+		panic(err)
+	}
+	return s
+}
+
+// Close store, the file stays open while other callers of NewStore still hold it.
+// The last Close stops background work such as the expiry reaper and waits for it, closes
+// every Watch channel and then the file. Later calls return nil and other methods ErrClosed.
+// Close is only idempotent for a store no one else holds, see NewStore for shared ones.
+func (s *Store) Close() (err error) {
+	if s.release() {
+		return nil
+	}
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		close(s.done)
+		s.mu.Unlock()
+		s.wg.Wait()
+
+		s.mu.Lock()
+		for name, chs := range s.watchers {
+			for _, ch := range chs {
+				close(ch)
+			}
+			delete(s.watchers, name)
+		}
+		s.mu.Unlock()
+		s.swap.Lock()
+		err = s.db.Close()
+		s.swap.Unlock()
+	})
+	return
+}
+
+// Sync fsyncs the database file, only needed when it was opened with NoSync
+// since every commit is already synced by default
+func (s *Store) Sync() error {
+	if s.closed() {
+		return ErrClosed
+	}
+	s.swap.RLock()
+	defer s.swap.RUnlock()
+	return s.db.Sync()
+}
+
+// goBackground runs fn in a goroutine that Close waits for, it reports false if the store is already closed
+func (s *Store) goBackground(fn func()) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed() {
+		return false
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		fn()
+	}()
+	return true
+}
+
+// closed reports whether Close has started
+func (s *Store) closed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
 	}
-	return &Store{db: db}
 }
 
-// Close store
-func (s *Store) Close() error {
-	return s.db.Close()
+// Check walks every page of the database and returns the inconsistencies found, none if it is healthy
+func (s *Store) Check() (errs []error) {
+	err := s.View(func(tx *Tx) error {
+		for err := range tx.tx.Check() {
+			errs = append(errs, err)
+		}
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return
 }
 
 // CreateBucketIfNotExist create bucket if not exist
 func (s *Store) CreateBucketIfNotExist(bucket []byte) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(bucket)
+	return s.CreateBuckets(bucket)
+}
+
+// CreateBuckets create every bucket that does not exist in one transaction
+func (s *Store) CreateBuckets(buckets ...[]byte) error {
+	return s.Update(func(tx *Tx) error {
+		for _, bucket := range buckets {
+			if _, err := tx.tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteBucket delete bucket and everything in it
+func (s *Store) DeleteBucket(bucket []byte) error {
+	return s.Update(func(tx *Tx) error {
+		err := tx.tx.DeleteBucket(bucket)
+		if err == bolt.ErrBucketNotFound {
+			return ErrBucketNotFound
+		}
 		if err != nil {
-			panic(err)
+			return err
 		}
-		return err
+		return tx.dropHistory(bucket)
 	})
 }
 
-// Incr increase a number
-func (s *Store) Incr(bucket, key []byte) (n uint64, err error) {
-	err = s.db.Update(func(tx *bolt.Tx) error {
-		data := make([]byte, 8)
-		b := tx.Bucket(bucket)
-		if old := b.Get(key); old != nil {
-			n = binary.BigEndian.Uint64(old)
-		}
-		// if n is max, keep it
-		if n == math.MaxUint64 {
+// RenameBucket moves everything in oldName, nested buckets, the sequence and the history of
+// a VersionedStore included, to a new bucket newName in one transaction. Vals are copied as
+// stored so TTLs carry over, and as nothing is written anew save hooks do not run for them.
+func (s *Store) RenameBucket(oldName, newName []byte) error {
+	return s.Update(func(tx *Tx) error {
+		src, err := tx.bucket(oldName)
+		if err != nil {
+			return err
+		}
+		dst, err := tx.tx.CreateBucket(newName)
+		if err == bolt.ErrBucketExists {
+			return ErrBucketExists
+		}
+		if err != nil {
+			return err
+		}
+		if err = copyBucket(dst, src); err != nil {
+			return err
+		}
+		if err = tx.tx.DeleteBucket(oldName); err != nil {
+			return err
+		}
+
+		// the version history of oldName moves with it
+		if hsrc := tx.tx.Bucket(historyName(oldName)); hsrc != nil {
+			if err = tx.dropHistory(newName); err != nil {
+				return err
+			}
+			hdst, err := tx.tx.CreateBucket(historyName(newName))
+			if err != nil {
+				return err
+			}
+			if err = copyBucket(hdst, hsrc); err != nil {
+				return err
+			}
+			return tx.dropHistory(oldName)
+		}
+		return nil
+	})
+}
+
+// Truncate delete everything in bucket but keep the bucket itself and its sequence,
+// returns how many entries were removed with each nested bucket counting as one
+func (s *Store) Truncate(bucket []byte) (deleted int, err error) {
+	err = s.Update(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+
+		var subs [][]byte
+		_ = b.ForEach(func(k, v []byte) error {
+			if v == nil {
+				subs = append(subs, k)
+			}
 			return nil
+		})
+		for _, k := range subs {
+			if err := b.DeleteBucket(k); err != nil {
+				return err
+			}
+			deleted++
 		}
-		n += 1
-		binary.BigEndian.PutUint64(data, n)
-		return b.Put(key, data)
+
+		n, err := tx.deleteFrom(bucket, b.Cursor(), nil, func([]byte) bool { return true })
+		deleted += n
+		if err != nil {
+			return err
+		}
+		return tx.dropHistory(bucket)
 	})
 	return
 }
 
-// Decr decrease a number
-func (s *Store) Decr(bucket, key []byte) (n uint64, err error) {
-	err = s.db.Update(func(tx *bolt.Tx) error {
-		data := make([]byte, 8)
-		b := tx.Bucket(bucket)
-		if old := b.Get(key); old != nil {
-			n = binary.BigEndian.Uint64(old)
-		}
-		// if n is min, keep it
-		if n == 0 {
+// ListBuckets returns the names of all top-level buckets in key order, without the
+// buckets holding the history of a VersionedStore
+func (s *Store) ListBuckets() (names [][]byte, err error) {
+	names = [][]byte{}
+	err = s.View(func(tx *Tx) error {
+		return tx.tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if !isHistory(name) {
+				names = append(names, clone(name))
+			}
 			return nil
-		}
-		n -= 1
-		binary.BigEndian.PutUint64(data, n)
-		return b.Put(key, data)
+		})
+	})
+	return
+}
+
+// Incr increase a number, a missing key counts as zero and the result stops at math.MaxUint64
+func (s *Store) Incr(bucket, key []byte) (n uint64, err error) {
+	return s.IncrBy(bucket, key, 1)
+}
+
+// Decr decrease a number, a missing key counts as zero and the result stops at zero
+func (s *Store) Decr(bucket, key []byte) (n uint64, err error) {
+	return s.IncrBy(bucket, key, -1)
+}
+
+// IncrBy add delta to a number, the result is kept between zero and math.MaxUint64
+func (s *Store) IncrBy(bucket, key []byte, delta int64) (n uint64, err error) {
+	defer s.observe("Incr", time.Now(), &err)
+	if err = validate(bucket, key); err != nil {
+		return
+	}
+	err = s.Update(func(tx *Tx) error {
+		n, err = tx.IncrBy(bucket, key, delta)
+		return err
+	})
+	return
+}
+
+// IncrBatched increase a number sharing a transaction with concurrent callers, see SaveBatched
+func (s *Store) IncrBatched(bucket, key []byte) (n uint64, err error) {
+	err = s.batch(func(tx *Tx) error {
+		n, err = tx.Incr(bucket, key)
+		return err
 	})
 	return
 }
 
 // Save key and val to bucket
 func (s *Store) Save(bucket, key, val []byte) (err error) {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(bucket)
-		return b.Put(key, val)
+	defer s.observe("Save", time.Now(), &err)
+	if err = validate(bucket, key); err != nil {
+		return
+	}
+	return s.Update(func(tx *Tx) error {
+		return tx.Save(bucket, key, val)
 	})
 }
 
-// Get val by key from bucket
-func (s *Store) Get(bucket, key []byte) (val []byte, err error) {
-	err = s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(bucket)
-		val = b.Get(key)
-		if val == nil {
-			return ErrNotfound
+// SaveAutoBucket save key and val to bucket, creating bucket in the same transaction if it does not exist
+func (s *Store) SaveAutoBucket(bucket, key, val []byte) error {
+	return s.Update(func(tx *Tx) error {
+		b, err := tx.tx.CreateBucketIfNotExists(bucket)
+		if err != nil {
+			return err
 		}
-		return nil
+		return tx.put(bucket, b, key, val, 0)
+	})
+}
+
+// Append suffix to the val of key in bucket and returns the new val, a missing key counts as empty
+func (s *Store) Append(bucket, key, suffix []byte) (val []byte, err error) {
+	err = s.Update(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		if val, _, err = s.get(b, key, tx.now); err != nil {
+			return err
+		}
+		val = append(val, suffix...)
+		return tx.put(bucket, b, key, val, 0)
 	})
 	return
 }
 
-func (s *Store) Delete(bucket, key []byte) (err error) {
-	return s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(bucket)
-		return b.Delete(key)
+// SaveBatched save key and val to bucket sharing a transaction with concurrent callers,
+// it trades latency for throughput under many writers and is slower for a single one
+func (s *Store) SaveBatched(bucket, key, val []byte) error {
+	return s.batch(func(tx *Tx) error {
+		return tx.Save(bucket, key, val)
 	})
 }
 
-// Scan for bucket
-func (s *Store) Scan(bucket []byte, next func(key, val []byte) bool) error {
-	return s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(bucket)
-		return b.ForEach(func(k, v []byte) error {
-			if !next(k, v) {
-				return io.EOF
+// SaveBatch save all pairs to bucket in one transaction
+func (s *Store) SaveBatch(bucket []byte, pairs []KV) error {
+	return s.Update(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		for _, p := range pairs {
+			if err := tx.put(bucket, b, p.Key, p.Val, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReplaceBucket swaps the contents of bucket for pairs in one transaction, creating it if needed,
+// so readers see either the old or the new contents. Nested buckets and the history of a
+// VersionedStore go too, the sequence is kept.
+// Every pair and every page it dirties stay in memory until the commit, so a very large
+// dataset needs that much memory, Import commits in batches instead. Watchers only see the puts.
+func (s *Store) ReplaceBucket(bucket []byte, pairs []KV) error {
+	return s.Update(func(tx *Tx) error {
+		var seq uint64
+		if old := tx.tx.Bucket(bucket); old != nil {
+			seq = old.Sequence()
+			if err := tx.tx.DeleteBucket(bucket); err != nil {
+				return err
+			}
+			if err := tx.dropHistory(bucket); err != nil {
+				return err
 			}
+		}
+		b, err := tx.tx.CreateBucket(bucket)
+		if err != nil {
+			return err
+		}
+		if err = b.SetSequence(seq); err != nil {
+			return err
+		}
+		for _, p := range pairs {
+			if err := tx.put(bucket, b, p.Key, p.Val, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// WriteAll applies ops across buckets in one transaction, any failing op rolls back all of them
+func (s *Store) WriteAll(ops []WriteOp) error {
+	return s.Update(func(tx *Tx) error {
+		for _, op := range ops {
+			var err error
+			if op.Delete {
+				err = tx.Delete(op.Bucket, op.Key)
+			} else {
+				err = tx.Save(op.Bucket, op.Key, op.Val)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SaveIfNotExists save key and val to bucket only if key is absent
+func (s *Store) SaveIfNotExists(bucket, key, val []byte) (saved bool, err error) {
+	err = s.Update(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		_, ok, err := s.get(b, key, tx.now)
+		if err != nil || ok {
+			return err
+		}
+		saved = true
+		return tx.put(bucket, b, key, val, 0)
+	})
+	return
+}
+
+// CompareAndSwap save new to key only if its current val equals old,
+// a nil old means the key must not exist yet
+func (s *Store) CompareAndSwap(bucket, key, old, new []byte) (swapped bool, err error) {
+	err = s.Update(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		cur, ok, err := s.get(b, key, tx.now)
+		if err != nil {
+			return err
+		}
+		if (old == nil) == ok || !bytes.Equal(cur, old) {
 			return nil
-		})
+		}
+		swapped = true
+		return tx.put(bucket, b, key, new, 0)
 	})
+	return
 }
 
-// FindPrefix find val by prefix from bucket
-func (s *Store) FindPrefix(bucket, prefix []byte, next func(key, val []byte) bool) error {
-	return s.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(bucket).Cursor()
-		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
-			if !next(k, v) {
-				return io.EOF
+// Get val by key from bucket
+func (s *Store) Get(bucket, key []byte) (val []byte, err error) {
+	defer s.observe("Get", time.Now(), &err)
+	if err = validate(bucket, key); err != nil {
+		return
+	}
+	err = s.View(func(tx *Tx) error {
+		val, err = tx.Get(bucket, key)
+		return err
+	})
+	return
+}
+
+// Lookup get val by key from bucket, found is false for a missing key which is not an error
+func (s *Store) Lookup(bucket, key []byte) (val []byte, found bool, err error) {
+	val, err = s.Get(bucket, key)
+	if err == ErrNotfound {
+		return nil, false, nil
+	}
+	return val, err == nil, err
+}
+
+// GetOr get val by key from bucket or a copy of def if the key is missing
+func (s *Store) GetOr(bucket, key, def []byte) ([]byte, error) {
+	val, err := s.Get(bucket, key)
+	if err == ErrNotfound {
+		return clone(def), nil
+	}
+	return val, err
+}
+
+// MultiGet vals by keys from bucket in one transaction,
+// vals keep the order of keys and missing keys are nil
+func (s *Store) MultiGet(bucket []byte, keys [][]byte) (vals [][]byte, err error) {
+	err = s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		vals = make([][]byte, len(keys))
+		for i, key := range keys {
+			if vals[i], _, err = s.get(b, key, tx.now); err != nil {
+				return err
 			}
 		}
 		return nil
 	})
+	return
 }
 
-// FindBetween find val between start and end from bucket
-func (s *Store) FindBetween(bucket, start, end []byte, next func(key, val []byte) bool) error {
-	return s.db.View(func(tx *bolt.Tx) error {
-		if bytes.Compare(start, end) > 0 {
-			start, end = end, start
+// First returns the smallest key and its val in bucket
+func (s *Store) First(bucket []byte) (key, val []byte, err error) {
+	return s.edge(bucket, true)
+}
+
+// Last returns the largest key and its val in bucket
+func (s *Store) Last(bucket []byte) (key, val []byte, err error) {
+	return s.edge(bucket, false)
+}
+
+func (s *Store) edge(bucket []byte, first bool) (key, val []byte, err error) {
+	err = s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		c := b.Cursor()
+		k, v := c.First()
+		step := c.Next
+		if !first {
+			k, v = c.Last()
+			step = c.Prev
+		}
+		for ; k != nil; k, v = step() {
+			var ok bool
+			if val, ok, err = s.value(v, tx.now); err != nil || ok {
+				key = clone(k)
+				return err
+			}
+		}
+		return ErrNotfound
+	})
+	return
+}
+
+// Neighbors returns the keys before and after key in bucket, nil at either end.
+// key need not exist, the neighbors are then those of where it would sort.
+func (s *Store) Neighbors(bucket, key []byte) (prev, next []byte, err error) {
+	err = s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		// live returns the first live key from k, v on, stepping with step
+		live := func(k, v []byte, step func() ([]byte, []byte)) ([]byte, error) {
+			for ; k != nil; k, v = step() {
+				if _, ok, err := s.value(v, tx.now); err != nil || ok {
+					return clone(k), err
+				}
+			}
+			return nil, nil
 		}
 
-		c := tx.Bucket(bucket).Cursor()
-		for k, v := c.Seek(start); k != nil && bytes.Compare(k, end) <= 0; k, v = c.Next() {
-			if !next(k, v) {
-				return io.EOF
+		c := b.Cursor()
+		k, v := c.Seek(key)
+		if bytes.Equal(k, key) {
+			k, v = c.Next()
+		}
+		if next, err = live(k, v, c.Next); err != nil {
+			return err
+		}
+
+		if k, v = c.Seek(key); k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		prev, err = live(k, v, c.Prev)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return
+}
+
+// TopN returns the n largest keys of bucket and their vals, largest first
+func (s *Store) TopN(bucket []byte, n int) (keys, vals [][]byte, err error) {
+	return s.edgeN(bucket, n, false)
+}
+
+// BottomN returns the n smallest keys of bucket and their vals, smallest first
+func (s *Store) BottomN(bucket []byte, n int) (keys, vals [][]byte, err error) {
+	return s.edgeN(bucket, n, true)
+}
+
+func (s *Store) edgeN(bucket []byte, n int, first bool) (keys, vals [][]byte, err error) {
+	err = s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		c := b.Cursor()
+		k, v := c.First()
+		step := c.Next
+		if !first {
+			k, v = c.Last()
+			step = c.Prev
+		}
+		for ; k != nil && len(keys) < n; k, v = step() {
+			val, ok, err := s.value(v, tx.now)
+			if err != nil {
+				return err
+			}
+			if ok {
+				keys = append(keys, clone(k))
+				vals = append(vals, val)
 			}
 		}
 		return nil
 	})
+	return
+}
+
+// Exists reports whether key is in bucket without copying its val
+func (s *Store) Exists(bucket, key []byte) (ok bool, err error) {
+	err = s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		raw := b.Get(key)
+		if raw == nil {
+			return nil
+		}
+		_, expire, _, err := frame(raw)
+		ok = err == nil && !expired(expire, tx.now)
+		return err
+	})
+	return
+}
+
+// CountPrefix returns the number of keys with prefix in bucket without reading vals,
+// expired keys count until they are reaped and nested buckets are left out
+func (s *Store) CountPrefix(bucket, prefix []byte) (n int, err error) {
+	err = s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		c := b.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if v != nil {
+				n++
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// CountBetween returns how many keys between start and end in bucket DeleteBetween would remove,
+// expired keys that have not been reaped yet are counted like in CountPrefix
+func (s *Store) CountBetween(bucket, start, end []byte) (n int, err error) {
+	err = s.View(func(tx *Tx) error {
+		if bytes.Compare(start, end) > 0 {
+			start, end = end, start
+		}
+
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		c := b.Cursor()
+		for k, v := c.Seek(start); k != nil && bytes.Compare(k, end) <= 0; k, v = c.Next() {
+			if v != nil {
+				n++
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// ValueLen returns the length of the val of key in bucket without copying it,
+// unless it is stored compressed or encrypted and has to be decoded to know
+func (s *Store) ValueLen(bucket, key []byte) (n int, err error) {
+	err = s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		raw := b.Get(key)
+		if raw == nil {
+			return ErrNotfound
+		}
+		flags, expire, body, err := frame(raw)
+		if err != nil {
+			return err
+		}
+		if flags&(flagCompressed|flagEncrypted) != 0 {
+			body, expire, err = s.decode(raw)
+			if err != nil {
+				return err
+			}
+		}
+		if expired(expire, tx.now) {
+			return ErrNotfound
+		}
+		n = len(body)
+		return nil
+	})
+	return
+}
+
+// Count returns the number of keys in bucket from its stats,
+// nested buckets count as a key and their own keys are included too
+func (s *Store) Count(bucket []byte) (n int, err error) {
+	err = s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		n = b.Stats().KeyN
+		return nil
+	})
+	return
+}
+
+// Delete key from bucket
+func (s *Store) Delete(bucket, key []byte) (err error) {
+	defer s.observe("Delete", time.Now(), &err)
+	if err = validate(bucket, key); err != nil {
+		return
+	}
+	return s.Update(func(tx *Tx) error {
+		return tx.Delete(bucket, key)
+	})
+}
+
+// DeleteBatch delete keys from bucket in one transaction and returns how many existed,
+// expired keys are deleted too but not counted as Get no longer finds them
+func (s *Store) DeleteBatch(bucket []byte, keys [][]byte) (deleted int, err error) {
+	err = s.Update(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if b.Get(key) == nil {
+				continue
+			}
+			_, ok, err := s.get(b, key, tx.now)
+			if err != nil {
+				return err
+			}
+			if err := tx.Delete(bucket, key); err != nil {
+				return err
+			}
+			if ok {
+				deleted++
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// DeleteIf delete key from bucket only if its val equals expected, a missing key is not deleted
+func (s *Store) DeleteIf(bucket, key, expected []byte) (deleted bool, err error) {
+	err = s.Update(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		cur, ok, err := s.get(b, key, tx.now)
+		if err != nil || !ok || !bytes.Equal(cur, expected) {
+			return err
+		}
+		deleted = true
+		return tx.Delete(bucket, key)
+	})
+	return
+}
+
+// GetDelete get val by key from bucket and delete the key in the same transaction
+func (s *Store) GetDelete(bucket, key []byte) (val []byte, err error) {
+	err = s.Update(func(tx *Tx) error {
+		if val, err = tx.Get(bucket, key); err != nil {
+			return err
+		}
+		return tx.Delete(bucket, key)
+	})
+	return
+}
+
+// Move the val of srcKey to dstKey in bucket, see MoveAcross
+func (s *Store) Move(bucket, srcKey, dstKey []byte) error {
+	return s.MoveAcross(bucket, srcKey, bucket, dstKey)
+}
+
+// MoveAcross the val of srcKey in srcBucket to dstKey in dstBucket in one transaction,
+// the val is moved as stored so a TTL set on it carries over
+func (s *Store) MoveAcross(srcBucket, srcKey, dstBucket, dstKey []byte) error {
+	return s.Update(func(tx *Tx) error {
+		src, err := tx.bucket(srcBucket)
+		if err != nil {
+			return err
+		}
+		dst, err := tx.bucket(dstBucket)
+		if err != nil {
+			return err
+		}
+
+		raw := src.Get(srcKey)
+		val, ok, err := s.value(raw, tx.now)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotfound
+		}
+		if bytes.Equal(srcBucket, dstBucket) && bytes.Equal(srcKey, dstKey) {
+			return nil
+		}
+
+		if err = dst.Put(dstKey, clone(raw)); err != nil {
+			return err
+		}
+		if err = tx.saved(dstBucket, dstKey, val); err != nil {
+			return err
+		}
+		return tx.Delete(srcBucket, srcKey)
+	})
+}
+
+// DeletePrefix delete all keys with prefix from bucket and returns how many were removed,
+// CountPrefix previews the count without deleting
+func (s *Store) DeletePrefix(bucket, prefix []byte) (deleted int, err error) {
+	err = s.Update(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		deleted, err = tx.deleteFrom(bucket, b.Cursor(), prefix, func(k []byte) bool {
+			return bytes.HasPrefix(k, prefix)
+		})
+		return err
+	})
+	return
+}
+
+// DeleteBetween delete all keys between start and end from bucket and returns how many were removed,
+// CountBetween previews the count without deleting
+func (s *Store) DeleteBetween(bucket, start, end []byte) (deleted int, err error) {
+	err = s.Update(func(tx *Tx) error {
+		if bytes.Compare(start, end) > 0 {
+			start, end = end, start
+		}
+
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		deleted, err = tx.deleteFrom(bucket, b.Cursor(), start, func(k []byte) bool {
+			return bytes.Compare(k, end) <= 0
+		})
+		return err
+	})
+	return
+}
+
+// Scan for bucket
+func (s *Store) Scan(bucket []byte, next func(key, val []byte) bool) (err error) {
+	defer s.observe("Scan", time.Now(), &err)
+	return ignoreStop(s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		return tx.scan(b, next)
+	}))
+}
+
+// ScanContext for bucket like Scan but aborts with ctx.Err() once ctx is done,
+// ctx is checked every scanCheckInterval keys to keep the check cheap
+func (s *Store) ScanContext(ctx context.Context, bucket []byte, next func(key, val []byte) bool) (err error) {
+	defer s.observe("ScanContext", time.Now(), &err)
+	return ignoreStop(s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		i := 0
+		return b.ForEach(func(k, v []byte) error {
+			if i%scanCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			i++
+			val, ok, err := s.value(v, tx.now)
+			if err != nil || !ok {
+				return err
+			}
+			if !next(clone(k), val) {
+				return errStop
+			}
+			return nil
+		})
+	}))
+}
+
+// ScanKeys for bucket without copying vals, only the header of each val is read to skip expired
+// keys, so checksums are not verified and a corrupt body does not stop the scan
+func (s *Store) ScanKeys(bucket []byte, next func(key []byte) bool) (err error) {
+	defer s.observe("ScanKeys", time.Now(), &err)
+	return ignoreStop(s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v == nil {
+				continue
+			}
+			expire, err := expiry(v)
+			if err != nil {
+				return err
+			}
+			if !expired(expire, tx.now) && !next(clone(k)) {
+				return errStop
+			}
+		}
+		return nil
+	}))
+}
+
+// ScanReverse for bucket from the last key to the first
+func (s *Store) ScanReverse(bucket []byte, next func(key, val []byte) bool) (err error) {
+	defer s.observe("ScanReverse", time.Now(), &err)
+	return ignoreStop(s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			val, ok, err := s.value(v, tx.now)
+			if err != nil {
+				return err
+			}
+			if ok && !next(clone(k), val) {
+				return errStop
+			}
+		}
+		return nil
+	}))
+}
+
+// MergeScan iterates the keys of all buckets as one stream in key order in a single transaction,
+// a key found in several buckets is passed once per bucket in the order buckets are given
+func (s *Store) MergeScan(buckets [][]byte, next func(bucket, key, val []byte) bool) (err error) {
+	defer s.observe("MergeScan", time.Now(), &err)
+	return ignoreStop(s.View(func(tx *Tx) error {
+		type head struct {
+			c    *bolt.Cursor
+			k, v []byte
+		}
+		heads := make([]head, len(buckets))
+		for i, name := range buckets {
+			b, err := tx.bucket(name)
+			if err != nil {
+				return err
+			}
+			c := b.Cursor()
+			k, v := c.First()
+			heads[i] = head{c: c, k: k, v: v}
+		}
+
+		for {
+			min := -1
+			for i, h := range heads {
+				// the strict compare keeps the first bucket on ties
+				if h.k != nil && (min < 0 || bytes.Compare(h.k, heads[min].k) < 0) {
+					min = i
+				}
+			}
+			if min < 0 {
+				return nil
+			}
+			h := &heads[min]
+			val, ok, err := s.value(h.v, tx.now)
+			if err != nil {
+				return err
+			}
+			if ok && !next(buckets[min], clone(h.k), val) {
+				return errStop
+			}
+			h.k, h.v = h.c.Next()
+		}
+	}))
+}
+
+// ScanFrom for bucket like Scan but starting at the first key after the key after, nil after starts
+// at the beginning. Passing the last key seen resumes an interrupted scan without overlap.
+func (s *Store) ScanFrom(bucket, after []byte, next func(key, val []byte) bool) (err error) {
+	defer s.observe("ScanFrom", time.Now(), &err)
+	return ignoreStop(s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		c := b.Cursor()
+		for k, v := seekAfter(c, after); k != nil; k, v = c.Next() {
+			val, ok, err := s.value(v, tx.now)
+			if err != nil {
+				return err
+			}
+			if ok && !next(clone(k), val) {
+				return errStop
+			}
+		}
+		return nil
+	}))
+}
+
+// Groups returns the distinct parts before the first sep of the keys in bucket in key order,
+// keys without sep belong to no group. Each group is skipped with one seek past its keys.
+func (s *Store) Groups(bucket, sep []byte) (groups [][]byte, err error) {
+	groups = [][]byte{}
+	err = s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; {
+			_, ok, err := s.value(v, tx.now)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				k, v = c.Next()
+				continue
+			}
+
+			i := bytes.Index(k, sep)
+			if i < 0 || len(sep) == 0 {
+				k, v = c.Next()
+				continue
+			}
+			groups = append(groups, clone(k[:i]))
+			end := prefixEnd(k[:i+len(sep)])
+			if end == nil {
+				break
+			}
+			k, v = c.Seek(end)
+		}
+		return nil
+	})
+	return
+}
+
+// Page returns up to limit pairs from bucket after the key after, nil after starts at the beginning
+// and a limit of zero or less returns every remaining pair in one page.
+// nextCursor is the after for the following page and nil once the bucket is exhausted
+func (s *Store) Page(bucket, after []byte, limit int) (keys [][]byte, vals [][]byte, nextCursor []byte, err error) {
+	defer s.observe("Page", time.Now(), &err)
+	err = s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		c := b.Cursor()
+		for k, v := seekAfter(c, after); k != nil; k, v = c.Next() {
+			val, ok, err := s.value(v, tx.now)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			// a live key past the limit means there is another page
+			if limit > 0 && len(keys) == limit {
+				nextCursor = keys[len(keys)-1]
+				return nil
+			}
+			keys = append(keys, clone(k))
+			vals = append(vals, val)
+		}
+		return nil
+	})
+	return
+}
+
+// FindPrefix find val by prefix from bucket
+func (s *Store) FindPrefix(bucket, prefix []byte, next func(key, val []byte) bool) error {
+	return s.FindPrefixLimit(bucket, prefix, 0, next)
+}
+
+// FindPrefixLimit find val by prefix from bucket, stopping after limit matches, zero means no limit
+func (s *Store) FindPrefixLimit(bucket, prefix []byte, limit int, next func(key, val []byte) bool) (err error) {
+	defer s.observe("FindPrefix", time.Now(), &err)
+	return ignoreStop(s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		return tx.findPrefix(b, prefix, limit, next)
+	}))
+}
+
+// LastWithPrefix returns the largest key with prefix and its val in bucket
+func (s *Store) LastWithPrefix(bucket, prefix []byte) (key, val []byte, err error) {
+	defer s.observe("LastWithPrefix", time.Now(), &err)
+	err = s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+
+		// the first key past the prefix range is its upper bound, step back from there
+		c := b.Cursor()
+		var k, v []byte
+		if end := prefixEnd(prefix); end == nil {
+			k, v = c.Last()
+		} else if k, _ = c.Seek(end); k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+
+		for ; k != nil && bytes.HasPrefix(k, prefix); k, v = c.Prev() {
+			var ok bool
+			if val, ok, err = s.value(v, tx.now); err != nil || ok {
+				key = clone(k)
+				return err
+			}
+		}
+		return ErrNotfound
+	})
+	return
+}
+
+// FindSuffix find val by suffix from bucket, keys are not indexed by suffix so this walks the whole bucket
+func (s *Store) FindSuffix(bucket, suffix []byte, next func(key, val []byte) bool) (err error) {
+	defer s.observe("FindSuffix", time.Now(), &err)
+	return ignoreStop(s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(k, v []byte) error {
+			if !bytes.HasSuffix(k, suffix) {
+				return nil
+			}
+			val, ok, err := s.value(v, tx.now)
+			if err != nil || !ok {
+				return err
+			}
+			if !next(clone(k), val) {
+				return errStop
+			}
+			return nil
+		})
+	}))
+}
+
+// FindBetween find val between start and end from bucket, both ends included
+func (s *Store) FindBetween(bucket, start, end []byte, next func(key, val []byte) bool) error {
+	return s.FindBetweenEx(bucket, start, end, true, true, next)
+}
+
+// FindBetweenEx find val between start and end from bucket, each end is
+// included only if its flag is set. Swapped ends are normalized like FindBetween,
+// the flags stay with start and end as passed.
+func (s *Store) FindBetweenEx(bucket, start, end []byte, startInclusive, endInclusive bool, next func(key, val []byte) bool) (err error) {
+	defer s.observe("FindBetween", time.Now(), &err)
+	return ignoreStop(s.View(func(tx *Tx) error {
+		if bytes.Compare(start, end) > 0 {
+			start, end = end, start
+			startInclusive, endInclusive = endInclusive, startInclusive
+		}
+
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		c := b.Cursor()
+		k, v := c.Seek(start)
+		if !startInclusive && bytes.Equal(k, start) {
+			k, v = c.Next()
+		}
+		for ; k != nil; k, v = c.Next() {
+			if cmp := bytes.Compare(k, end); cmp > 0 || (cmp == 0 && !endInclusive) {
+				break
+			}
+			val, ok, err := s.value(v, tx.now)
+			if err != nil {
+				return err
+			}
+			if ok && !next(clone(k), val) {
+				return errStop
+			}
+		}
+		return nil
+	}))
+}
+
+// copyBucket copies src into dst as stored, recursing into nested buckets
+func copyBucket(dst, src *bolt.Bucket) error {
+	if err := dst.SetSequence(src.Sequence()); err != nil {
+		return err
+	}
+	return src.ForEach(func(k, v []byte) error {
+		// src memory may be remapped by the writes to dst
+		if v != nil {
+			return dst.Put(clone(k), clone(v))
+		}
+		sub, err := dst.CreateBucket(clone(k))
+		if err != nil {
+			return err
+		}
+		return copyBucket(sub, src.Bucket(k))
+	})
+}
+
+// validate rejects the empty bucket names and keys bbolt would fail on with less clear errors
+func validate(bucket, key []byte) error {
+	if len(bucket) == 0 {
+		return ErrEmptyBucket
+	}
+	if len(key) == 0 {
+		return ErrEmptyKey
+	}
+	return nil
+}
+
+// seekAfter moves c to the first key after after, or the first key when after is nil
+func seekAfter(c *bolt.Cursor, after []byte) (k, v []byte) {
+	if after == nil {
+		return c.First()
+	}
+	if k, v = c.Seek(after); k != nil && bytes.Equal(k, after) {
+		k, v = c.Next()
+	}
+	return
+}
+
+// add delta to the big-endian number stored at key, saturating instead of wrapping
+func add(b *bolt.Bucket, key []byte, delta int64) (uint64, error) {
+	n, err := number(b.Get(key))
+	if err != nil {
+		return 0, err
+	}
+
+	old := n
+	if delta >= 0 {
+		if d := uint64(delta); n > math.MaxUint64-d {
+			n = math.MaxUint64
+		} else {
+			n += d
+		}
+	} else {
+		// -(delta+1)+1 avoids overflowing on math.MinInt64
+		if d := uint64(-(delta + 1)) + 1; n < d {
+			n = 0
+		} else {
+			n -= d
+		}
+	}
+	// keep it if nothing changed
+	if n == old {
+		return n, nil
+	}
+
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, n)
+	return n, b.Put(key, data)
+}
+
+// prefixEnd returns the smallest key greater than every key with prefix, nil if there is none
+func prefixEnd(prefix []byte) []byte {
+	end := clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// openError classifies a bolt.Open failure as ErrLocked, ErrCorrupt or ErrPermission.
+// A lock is only reported when the bbolt options set a Timeout, otherwise open waits for it.
+func openError(err error) error {
+	switch {
+	case err == bolt.ErrTimeout:
+		return fmt.Errorf("%w: %v", ErrLocked, err)
+	case err == bolt.ErrInvalid, err == bolt.ErrVersionMismatch, err == bolt.ErrChecksum,
+		// bbolt has no sentinel for a file shorter than its two meta pages
+		strings.Contains(err.Error(), "file size too small"):
+		return fmt.Errorf("%w: %v", ErrCorrupt, err)
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("%w: %v", ErrPermission, err)
+	}
+	return err
+}
+
+// errStop ends an iteration early when next returns false
+var errStop = errors.New("stop iteration")
+
+// ignoreStop hides errStop from callers, stopping early is not a failure
+func ignoreStop(err error) error {
+	if err == errStop {
+		return nil
+	}
+	return err
+}
+
+// number decodes a val written by add, a missing val is zero
+func number(val []byte) (uint64, error) {
+	if val == nil {
+		return 0, nil
+	}
+	if len(val) != 8 {
+		return 0, ErrInvalidNumber
+	}
+	return binary.BigEndian.Uint64(val), nil
+}
+
+// clone copies b out of the transaction memory, bbolt slices are only valid while it is open
+func clone(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
 }
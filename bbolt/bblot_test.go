@@ -0,0 +1,1440 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestNewStoreWithRetry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.db")
+	held, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = held.Close()
+	}()
+
+	s, err := NewStoreWithRetry(path, 20, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Close()
+}
+
+func TestNewStoreWithRetryAttempts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.db")
+	for _, attempts := range []int{0, -1} {
+		s, err := NewStoreWithRetry(path, attempts, time.Millisecond)
+		if s != nil || !errors.Is(err, ErrInvalidArgument) {
+			t.Fatalf("attempts %d: NewStoreWithRetry = %v, %v, want ErrInvalidArgument", attempts, s, err)
+		}
+	}
+}
+
+func TestScanKeys(t *testing.T) {
+	s := newTestStore(t, WithChecksum())
+	bucket := []byte("b")
+	mustSave(t, s, "b", "a", "v")
+	mustSave(t, s, "b", "corrupt", "v")
+	if err := s.SaveWithTTL(bucket, []byte("expired"), []byte("v"), time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveWithTTL(bucket, []byte("z"), []byte("v"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	// flip a body byte so only a full read notices
+	err := s.Update(func(tx *Tx) error {
+		b := tx.tx.Bucket(bucket)
+		raw := clone(b.Get([]byte("corrupt")))
+		raw[headerSize] ^= 0xff
+		return b.Put([]byte("corrupt"), raw)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	if err := s.ScanKeys(bucket, func(key []byte) bool {
+		keys = append(keys, string(key))
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(keys); got != "[a corrupt z]" {
+		t.Fatalf("ScanKeys = %s, want [a corrupt z]", got)
+	}
+	if err := s.Scan(bucket, func(key, val []byte) bool { return true }); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Scan = %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func benchmarkScanStore(b *testing.B) *Store {
+	s := newTestStore(b, WithChecksum())
+	pairs := make([]KV, 1000)
+	for i := range pairs {
+		pairs[i] = KV{Key: []byte(fmt.Sprintf("k%04d", i)), Val: bytes.Repeat([]byte("v"), 4096)}
+	}
+	if err := s.CreateBucketIfNotExist([]byte("b")); err != nil {
+		b.Fatal(err)
+	}
+	if err := s.SaveBatch([]byte("b"), pairs); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	return s
+}
+
+func BenchmarkScanKeys(b *testing.B) {
+	s := benchmarkScanStore(b)
+	for i := 0; i < b.N; i++ {
+		if err := s.ScanKeys([]byte("b"), func(key []byte) bool { return true }); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkScan(b *testing.B) {
+	s := benchmarkScanStore(b)
+	for i := 0; i < b.N; i++ {
+		if err := s.Scan([]byte("b"), func(key, val []byte) bool { return true }); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	mustSave(t, s, "b", "k", "v")
+	if err := s.Delete(bucket, []byte("k")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(bucket, []byte("k")); !errors.Is(err, ErrNotfound) {
+		t.Fatalf("Get after Delete = %v, want ErrNotfound", err)
+	}
+}
+
+func TestGetScanCopies(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	mustSave(t, s, "b", "k", "original")
+
+	val, err := s.Get(bucket, []byte("k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys, vals [][]byte
+	if err := s.Scan(bucket, func(key, val []byte) bool {
+		keys, vals = append(keys, key), append(vals, val)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// rewrite the key and churn enough pages for the old ones to be reused
+	for i := 0; i < 500; i++ {
+		mustSave(t, s, "b", "k", fmt.Sprintf("changed%d", i))
+		mustSave(t, s, "b", fmt.Sprintf("fill%d", i), string(bytes.Repeat([]byte("x"), 512)))
+	}
+	if string(val) != "original" {
+		t.Fatalf("Get val changed to %q", val)
+	}
+	if len(keys) != 1 || string(keys[0]) != "k" || string(vals[0]) != "original" {
+		t.Fatalf("Scan pair changed to %q=%q", keys, vals)
+	}
+}
+
+func TestMissingBucket(t *testing.T) {
+	s := newTestStore(t)
+	b, k := []byte("missing"), []byte("k")
+	all := func(key, val []byte) bool { return true }
+	for name, call := range map[string]func() error{
+		"Incr":   func() error { _, err := s.Incr(b, k); return err },
+		"Decr":   func() error { _, err := s.Decr(b, k); return err },
+		"IncrBy": func() error { _, err := s.IncrBy(b, k, 5); return err },
+		"Save":   func() error { return s.Save(b, k, []byte("v")) },
+		"Get":    func() error { _, err := s.Get(b, k); return err },
+		"Delete": func() error { return s.Delete(b, k) },
+		"Exists": func() error { _, err := s.Exists(b, k); return err },
+		"Count":  func() error { _, err := s.Count(b); return err },
+		"Append": func() error { _, err := s.Append(b, k, []byte("v")); return err },
+		"MultiGet": func() error {
+			_, err := s.MultiGet(b, [][]byte{k})
+			return err
+		},
+		"SaveBatch": func() error { return s.SaveBatch(b, []KV{{Key: k, Val: k}}) },
+		"SaveIfNotExists": func() error {
+			_, err := s.SaveIfNotExists(b, k, []byte("v"))
+			return err
+		},
+		"CompareAndSwap": func() error {
+			_, err := s.CompareAndSwap(b, k, nil, []byte("v"))
+			return err
+		},
+		"First":        func() error { _, _, err := s.First(b); return err },
+		"Last":         func() error { _, _, err := s.Last(b); return err },
+		"Scan":         func() error { return s.Scan(b, all) },
+		"ScanReverse":  func() error { return s.ScanReverse(b, all) },
+		"FindPrefix":   func() error { return s.FindPrefix(b, k, all) },
+		"Page":         func() error { _, _, _, err := s.Page(b, nil, 10); return err },
+		"DeleteBucket": func() error { return s.DeleteBucket(b) },
+	} {
+		if err := call(); !errors.Is(err, ErrBucketNotFound) {
+			t.Errorf("%s = %v, want ErrBucketNotFound", name, err)
+		}
+	}
+}
+
+func TestDecr(t *testing.T) {
+	s := newTestStore(t)
+	bucket, key := []byte("b"), []byte("n")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.Decr(bucket, key); err != nil || n != 0 {
+		t.Fatalf("Decr of a missing key = %d, %v, want 0", n, err)
+	}
+	if ok, err := s.Exists(bucket, key); err != nil || ok {
+		t.Fatalf("Decr clamped at zero created the key: %v, %v", ok, err)
+	}
+	if _, err := s.IncrBy(bucket, key, 3); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.Decr(bucket, key); err != nil || n != 2 {
+		t.Fatalf("Decr = %d, %v, want 2", n, err)
+	}
+}
+
+func TestIncrBy(t *testing.T) {
+	s := newTestStore(t)
+	bucket, key := []byte("b"), []byte("n")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []struct {
+		delta int64
+		want  uint64
+	}{
+		{5, 5},
+		{-2, 3},
+		{-10, 0},
+		{math.MaxInt64, math.MaxInt64},
+		{math.MaxInt64, math.MaxUint64 - 1},
+		{math.MaxInt64, math.MaxUint64},
+		{math.MinInt64, math.MaxUint64 - 1<<63},
+		{math.MinInt64, 0},
+	} {
+		if n, err := s.IncrBy(bucket, key, step.delta); err != nil || n != step.want {
+			t.Fatalf("IncrBy(%d) = %d, %v, want %d", step.delta, n, err, step.want)
+		}
+	}
+}
+
+func benchmarkLargeVal(b *testing.B) *Store {
+	s := newTestStore(b)
+	if err := s.SaveAutoBucket([]byte("b"), []byte("k"), bytes.Repeat([]byte("v"), 1<<20)); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	return s
+}
+
+func BenchmarkExists(b *testing.B) {
+	s := benchmarkLargeVal(b)
+	for i := 0; i < b.N; i++ {
+		if ok, err := s.Exists([]byte("b"), []byte("k")); err != nil || !ok {
+			b.Fatal(ok, err)
+		}
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	s := benchmarkLargeVal(b)
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Get([]byte("b"), []byte("k")); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkPairs returns n pairs and a store with their bucket created
+func benchmarkPairs(b *testing.B, n int) (*Store, []KV) {
+	s := newTestStore(b)
+	if err := s.CreateBucketIfNotExist([]byte("b")); err != nil {
+		b.Fatal(err)
+	}
+	pairs := make([]KV, n)
+	for i := range pairs {
+		pairs[i] = KV{Key: []byte(fmt.Sprintf("k%04d", i)), Val: []byte("v")}
+	}
+	b.ResetTimer()
+	return s, pairs
+}
+
+func BenchmarkSaveBatch(b *testing.B) {
+	s, pairs := benchmarkPairs(b, 100)
+	for i := 0; i < b.N; i++ {
+		if err := s.SaveBatch([]byte("b"), pairs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSaveEach(b *testing.B) {
+	s, pairs := benchmarkPairs(b, 100)
+	for i := 0; i < b.N; i++ {
+		for _, p := range pairs {
+			if err := s.Save([]byte("b"), p.Key, p.Val); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestMultiGet(t *testing.T) {
+	s := newTestStore(t)
+	mustSave(t, s, "b", "a", "1")
+	mustSave(t, s, "b", "c", "3")
+	vals, err := s.MultiGet([]byte("b"), [][]byte{[]byte("c"), []byte("b"), []byte("a")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vals) != 3 || string(vals[0]) != "3" || vals[1] != nil || string(vals[2]) != "1" {
+		t.Fatalf("MultiGet = %q, want [3 <nil> 1]", vals)
+	}
+}
+
+func TestDeleteBucket(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	mustSave(t, s, "b", "k", "v")
+	if err := s.DeleteBucket(bucket); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(bucket, []byte("k")); !errors.Is(err, ErrBucketNotFound) {
+		t.Fatalf("Get after DeleteBucket = %v, want ErrBucketNotFound", err)
+	}
+	names, err := s.ListBuckets()
+	if err != nil || len(names) != 0 {
+		t.Fatalf("ListBuckets = %q, %v, want none", names, err)
+	}
+}
+
+func TestListBuckets(t *testing.T) {
+	s := newTestStore(t)
+	names, err := s.ListBuckets()
+	if err != nil || names == nil || len(names) != 0 {
+		t.Fatalf("ListBuckets on a fresh store = %#v, %v, want an empty slice", names, err)
+	}
+	if err := s.CreateBuckets([]byte("c"), []byte("a"), []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if names, err = s.ListBuckets(); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprintf("%s", names); got != "[a b c]" {
+		t.Fatalf("ListBuckets = %s, want [a b c]", got)
+	}
+}
+
+func TestCompareAndSwapContended(t *testing.T) {
+	s := newTestStore(t)
+	bucket, key := []byte("b"), []byte("k")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	const workers = 8
+	var cur []byte
+	for round := 0; round < 20; round++ {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var winners []string
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				next := fmt.Sprintf("r%d-w%d", round, w)
+				swapped, err := s.CompareAndSwap(bucket, key, cur, []byte(next))
+				if err != nil {
+					t.Error(err)
+				}
+				if swapped {
+					mu.Lock()
+					winners = append(winners, next)
+					mu.Unlock()
+				}
+			}(w)
+		}
+		wg.Wait()
+		if len(winners) != 1 {
+			t.Fatalf("round %d had winners %q, want one", round, winners)
+		}
+		val, err := s.Get(bucket, key)
+		if err != nil || string(val) != winners[0] {
+			t.Fatalf("round %d val = %q, %v, want %q", round, val, err, winners[0])
+		}
+		cur = val
+	}
+}
+
+func TestSaveIfNotExistsRace(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		key := []byte(fmt.Sprintf("k%d", i))
+		saved := make(chan bool, 2)
+		for _, val := range []string{"first", "second"} {
+			go func(val string) {
+				ok, err := s.SaveIfNotExists(bucket, key, []byte(val))
+				if err != nil {
+					t.Error(err)
+				}
+				saved <- ok
+			}(val)
+		}
+		if a, b := <-saved, <-saved; a == b {
+			t.Fatalf("key %s: saved %v and %v, want exactly one", key, a, b)
+		}
+	}
+}
+
+func TestScanReverse(t *testing.T) {
+	s := newTestStore(t)
+	for i := 1; i <= 5; i++ {
+		mustSave(t, s, "b", fmt.Sprint(i), "v")
+	}
+	var keys []string
+	if err := s.ScanReverse([]byte("b"), func(key, val []byte) bool {
+		keys = append(keys, string(key))
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(keys); got != "[5 4 3 2 1]" {
+		t.Fatalf("ScanReverse = %s, want [5 4 3 2 1]", got)
+	}
+}
+
+func TestPage(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	for i := 0; i < 100; i++ {
+		mustSave(t, s, "b", fmt.Sprintf("k%03d", i), "v")
+	}
+
+	var after []byte
+	seen := 0
+	for pages := 0; ; pages++ {
+		keys, _, next, err := s.Page(bucket, after, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range keys {
+			if want := fmt.Sprintf("k%03d", seen); string(key) != want {
+				t.Fatalf("page %d has %s, want %s", pages, key, want)
+			}
+			seen++
+		}
+		if next == nil {
+			break
+		}
+		if len(keys) != 10 {
+			t.Fatalf("page %d has %d keys, want 10", pages, len(keys))
+		}
+		after = next
+	}
+	if seen != 100 {
+		t.Fatalf("paged over %d keys, want 100", seen)
+	}
+
+	for _, limit := range []int{0, -1} {
+		if keys, _, next, err := s.Page(bucket, nil, limit); err != nil || len(keys) != 100 || next != nil {
+			t.Fatalf("Page limit %d = %d keys, next %q, %v", limit, len(keys), next, err)
+		}
+	}
+}
+
+func TestNewStoreWithOptionsTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locked.db")
+	held, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Close()
+
+	start := time.Now()
+	_, err = NewStoreWithOptions(path, &bolt.Options{Timeout: 50 * time.Millisecond})
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("NewStoreWithOptions = %v, want ErrLocked", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("gave up after %v, want about 50ms", d)
+	}
+}
+
+func TestDeletePrefix(t *testing.T) {
+	s := newTestStore(t)
+	for _, key := range []string{"a", "user:1", "user:2", "user", "users", "v"} {
+		mustSave(t, s, "b", key, "v")
+	}
+	if n, err := s.DeletePrefix([]byte("b"), []byte("user:")); err != nil || n != 2 {
+		t.Fatalf("DeletePrefix = %d, %v, want 2", n, err)
+	}
+	if got := keysOf(t, s, "b"); got != "[a user users v]" {
+		t.Fatalf("keys left = %s", got)
+	}
+}
+
+func TestDeleteBetween(t *testing.T) {
+	for name, bounds := range map[string][2]string{"ordered": {"b", "d"}, "swapped": {"d", "b"}} {
+		s := newTestStore(t)
+		for _, key := range []string{"a", "b", "c", "d", "e"} {
+			mustSave(t, s, "b", key, "v")
+		}
+		if n, err := s.DeleteBetween([]byte("b"), []byte(bounds[0]), []byte(bounds[1])); err != nil || n != 3 {
+			t.Fatalf("%s: DeleteBetween = %d, %v, want 3", name, n, err)
+		}
+		if got := keysOf(t, s, "b"); got != "[a e]" {
+			t.Fatalf("%s: keys left = %s, want [a e]", name, got)
+		}
+	}
+}
+
+func TestFindSuffix(t *testing.T) {
+	s := newTestStore(t)
+	for _, key := range []string{"a.json", "b.txt", "c.json", "json"} {
+		mustSave(t, s, "b", key, "v")
+	}
+	find := func(suffix string) string {
+		var keys []string
+		if err := s.FindSuffix([]byte("b"), []byte(suffix), func(key, val []byte) bool {
+			keys = append(keys, string(key))
+			return true
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprint(keys)
+	}
+	if got := find(".json"); got != "[a.json c.json]" {
+		t.Fatalf("FindSuffix(.json) = %s", got)
+	}
+	if got := find(".csv"); got != "[]" {
+		t.Fatalf("FindSuffix(.csv) = %s, want none", got)
+	}
+}
+
+func TestFirstLast(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.First(bucket); !errors.Is(err, ErrNotfound) {
+		t.Fatalf("First of an empty bucket = %v, want ErrNotfound", err)
+	}
+	if _, _, err := s.Last(bucket); !errors.Is(err, ErrNotfound) {
+		t.Fatalf("Last of an empty bucket = %v, want ErrNotfound", err)
+	}
+
+	check := func(first, last string) {
+		t.Helper()
+		if k, v, err := s.First(bucket); err != nil || string(k) != first || string(v) != "v"+first {
+			t.Fatalf("First = %q=%q, %v, want %s", k, v, err, first)
+		}
+		if k, v, err := s.Last(bucket); err != nil || string(k) != last || string(v) != "v"+last {
+			t.Fatalf("Last = %q=%q, %v, want %s", k, v, err, last)
+		}
+	}
+	mustSave(t, s, "b", "m", "vm")
+	check("m", "m")
+	mustSave(t, s, "b", "z", "vz")
+	mustSave(t, s, "b", "a", "va")
+	check("a", "z")
+}
+
+func TestSync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nosync.db")
+	s, err := NewStoreWithOptions(path, &bolt.Options{NoSync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustSave(t, s, "b", "k", "v")
+	if err := s.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Sync(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Sync after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestScanEarlyStop(t *testing.T) {
+	s := newTestStore(t)
+	for i := 0; i < 5; i++ {
+		mustSave(t, s, "b", fmt.Sprint(i), "v")
+	}
+	bucket := []byte("b")
+	for name, scan := range map[string]func(func(key, val []byte) bool) error{
+		"Scan":        func(next func(key, val []byte) bool) error { return s.Scan(bucket, next) },
+		"ScanReverse": func(next func(key, val []byte) bool) error { return s.ScanReverse(bucket, next) },
+		"FindPrefix":  func(next func(key, val []byte) bool) error { return s.FindPrefix(bucket, nil, next) },
+		"FindBetween": func(next func(key, val []byte) bool) error {
+			return s.FindBetween(bucket, []byte("0"), []byte("9"), next)
+		},
+	} {
+		n := 0
+		err := scan(func(key, val []byte) bool {
+			n++
+			return n < 2
+		})
+		if err != nil || n != 2 {
+			t.Errorf("%s stopped after %d with %v, want 2 and nil", name, n, err)
+		}
+	}
+}
+
+func TestGetOr(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	mustSave(t, s, "b", "k", "v")
+	if val, err := s.GetOr(bucket, []byte("k"), []byte("def")); err != nil || string(val) != "v" {
+		t.Fatalf("GetOr of a present key = %q, %v", val, err)
+	}
+	if val, err := s.GetOr(bucket, []byte("missing"), []byte("def")); err != nil || string(val) != "def" {
+		t.Fatalf("GetOr of an absent key = %q, %v, want def", val, err)
+	}
+	if _, err := s.GetOr([]byte("missing"), []byte("k"), []byte("def")); !errors.Is(err, ErrBucketNotFound) {
+		t.Fatalf("GetOr of a missing bucket = %v, want ErrBucketNotFound", err)
+	}
+}
+
+func TestAppendConcurrent(t *testing.T) {
+	s := newTestStore(t)
+	bucket, key := []byte("b"), []byte("log")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	const workers, appends = 8, 25
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < appends; i++ {
+				if _, err := s.Append(bucket, key, []byte("x")); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if val, err := s.Get(bucket, key); err != nil || len(val) != workers*appends {
+		t.Fatalf("val has %d bytes, %v, want %d", len(val), err, workers*appends)
+	}
+}
+
+func TestReadOnlyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ro.db")
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustSave(t, s, "b", "k", "v")
+	_ = s.Close()
+
+	ro, err := NewReadOnlyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if err := ro.Save([]byte("b"), []byte("k"), []byte("new")); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Save = %v, want ErrReadOnly", err)
+	}
+	if _, err := ro.Incr([]byte("b"), []byte("n")); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Incr = %v, want ErrReadOnly", err)
+	}
+	if val, err := ro.Get([]byte("b"), []byte("k")); err != nil || string(val) != "v" {
+		t.Fatalf("Get = %q, %v", val, err)
+	}
+}
+
+// benchmarkWriters runs save from 100 goroutines at once, b.N saves in all
+func benchmarkWriters(b *testing.B, save func(s *Store, key []byte) error) {
+	s, _ := benchmarkPairs(b, 0)
+	const writers = 100
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < b.N; i += writers {
+				if err := save(s, []byte(fmt.Sprintf("k%d", i))); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func BenchmarkSaveBatched(b *testing.B) {
+	benchmarkWriters(b, func(s *Store, key []byte) error {
+		return s.SaveBatched([]byte("b"), key, []byte("v"))
+	})
+}
+
+func BenchmarkSaveConcurrent(b *testing.B) {
+	benchmarkWriters(b, func(s *Store, key []byte) error {
+		return s.Save([]byte("b"), key, []byte("v"))
+	})
+}
+
+func TestTruncate(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	for i := 0; i < 10; i++ {
+		mustSave(t, s, "b", fmt.Sprint(i), "v")
+	}
+	if n, err := s.Truncate(bucket); err != nil || n != 10 {
+		t.Fatalf("Truncate = %d, %v, want 10", n, err)
+	}
+	if n, err := s.Count(bucket); err != nil || n != 0 {
+		t.Fatalf("Count after Truncate = %d, %v, want 0", n, err)
+	}
+	// the bucket itself is still there to write to
+	if err := s.Save(bucket, []byte("k"), []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScanContextCancel(t *testing.T) {
+	s := newTestStore(t)
+	pairs := make([]KV, 4*scanCheckInterval)
+	for i := range pairs {
+		pairs[i] = KV{Key: []byte(fmt.Sprintf("k%05d", i)), Val: []byte("v")}
+	}
+	if err := s.CreateBucketIfNotExist([]byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveBatch([]byte("b"), pairs); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := 0
+	err := s.ScanContext(ctx, []byte("b"), func(key, val []byte) bool {
+		if n++; n == 10 {
+			cancel()
+		}
+		return true
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ScanContext = %v, want context.Canceled", err)
+	}
+	if n > scanCheckInterval {
+		t.Fatalf("visited %d keys after cancel, want at most %d", n, scanCheckInterval)
+	}
+}
+
+func TestLastWithPrefix(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	for _, key := range []string{"a1", "b1", "a3", "b9", "a2", "c", "a\xff"} {
+		mustSave(t, s, "b", key, "v"+key)
+	}
+	for prefix, want := range map[string]string{"a": "a\xff", "b": "b9", "c": "c", "a1": "a1", "": "c"} {
+		if k, v, err := s.LastWithPrefix(bucket, []byte(prefix)); err != nil || string(k) != want || string(v) != "v"+want {
+			t.Errorf("LastWithPrefix(%q) = %q=%q, %v, want %q", prefix, k, v, err, want)
+		}
+	}
+	if _, _, err := s.LastWithPrefix(bucket, []byte("d")); !errors.Is(err, ErrNotfound) {
+		t.Fatalf("LastWithPrefix(d) = %v, want ErrNotfound", err)
+	}
+}
+
+func TestWriteAllRollback(t *testing.T) {
+	s := newTestStore(t)
+	mustSave(t, s, "a", "k", "old")
+	err := s.WriteAll([]WriteOp{
+		{Bucket: []byte("a"), Key: []byte("k"), Val: []byte("new")},
+		{Bucket: []byte("a"), Key: []byte("k2"), Val: []byte("v")},
+		{Bucket: []byte("missing"), Key: []byte("k"), Val: []byte("v")},
+	})
+	if !errors.Is(err, ErrBucketNotFound) {
+		t.Fatalf("WriteAll = %v, want ErrBucketNotFound", err)
+	}
+	if val, err := s.Get([]byte("a"), []byte("k")); err != nil || string(val) != "old" {
+		t.Fatalf("Get after the failed WriteAll = %q, %v, want old", val, err)
+	}
+	if got := keysOf(t, s, "a"); got != "[k]" {
+		t.Fatalf("keys = %s, want [k]", got)
+	}
+
+	mustSave(t, s, "b", "gone", "v")
+	if err := s.WriteAll([]WriteOp{
+		{Bucket: []byte("a"), Key: []byte("k"), Val: []byte("new")},
+		{Bucket: []byte("b"), Key: []byte("gone"), Delete: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := keysOf(t, s, "b"); got != "[]" {
+		t.Fatalf("keys of b = %s, want none", got)
+	}
+}
+
+func TestFindPrefixLimit(t *testing.T) {
+	s := newTestStore(t)
+	for i := 0; i < 10; i++ {
+		mustSave(t, s, "b", fmt.Sprintf("p%d", i), "v")
+	}
+	mustSave(t, s, "b", "q", "v")
+	var keys []string
+	if err := s.FindPrefixLimit([]byte("b"), []byte("p"), 3, func(key, val []byte) bool {
+		keys = append(keys, string(key))
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(keys); got != "[p0 p1 p2]" {
+		t.Fatalf("FindPrefixLimit = %s, want [p0 p1 p2]", got)
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	dir := t.TempDir()
+	locked := filepath.Join(dir, "locked.db")
+	held, err := bolt.Open(locked, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Close()
+	if _, err := NewStoreWithOptions(locked, &bolt.Options{Timeout: 10 * time.Millisecond}); !errors.Is(err, ErrLocked) {
+		t.Errorf("open of a locked file = %v, want ErrLocked", err)
+	}
+
+	truncated := filepath.Join(dir, "truncated.db")
+	s, err := NewStore(truncated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Close()
+	if err := os.Truncate(truncated, 100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewStore(truncated); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("open of a truncated file = %v, want ErrCorrupt", err)
+	}
+
+	garbage := filepath.Join(dir, "garbage.db")
+	if err := os.WriteFile(garbage, bytes.Repeat([]byte{0xab}, 1<<14), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewStore(garbage); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("open of a garbage file = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestGetDeleteConcurrent(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	const items = 200
+	for i := 0; i < items; i++ {
+		mustSave(t, s, "b", fmt.Sprintf("k%03d", i), fmt.Sprint(i))
+	}
+
+	var mu sync.Mutex
+	popped := map[string]int{}
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < items; i++ {
+				val, err := s.GetDelete(bucket, []byte(fmt.Sprintf("k%03d", i)))
+				if errors.Is(err, ErrNotfound) {
+					continue
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				popped[string(val)]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(popped) != items {
+		t.Fatalf("popped %d distinct items, want %d", len(popped), items)
+	}
+	for val, n := range popped {
+		if n != 1 {
+			t.Fatalf("item %s popped %d times", val, n)
+		}
+	}
+}
+
+func TestCountPrefix(t *testing.T) {
+	s := newTestStore(t)
+	for _, key := range []string{"a:1", "b:1", "a:2", "ab", "b:2", "a:3", "a"} {
+		mustSave(t, s, "b", key, "v")
+	}
+	for prefix, want := range map[string]int{"a:": 3, "b:": 2, "a": 5, "c": 0, "": 7} {
+		if n, err := s.CountPrefix([]byte("b"), []byte(prefix)); err != nil || n != want {
+			t.Errorf("CountPrefix(%q) = %d, %v, want %d", prefix, n, err, want)
+		}
+	}
+}
+
+func TestMkdirAllFileMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a", "b", "store.db")
+	s, err := NewStore(path, WithMkdirAll(), WithFileMode(0640))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0640 {
+		t.Fatalf("file mode = %v, want 0640", mode)
+	}
+	if info, err = os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+		t.Fatalf("directory = %v, %v", info, err)
+	}
+
+	if _, err := NewStore(filepath.Join(t.TempDir(), "missing", "store.db")); err == nil {
+		t.Fatal("opened under a missing directory without WithMkdirAll")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	s := newTestStore(t)
+	mustSave(t, s, "b", "k", "v")
+	if val, found, err := s.Lookup([]byte("b"), []byte("k")); err != nil || !found || string(val) != "v" {
+		t.Fatalf("Lookup of a present key = %q, %v, %v", val, found, err)
+	}
+	if val, found, err := s.Lookup([]byte("b"), []byte("absent")); err != nil || found || val != nil {
+		t.Fatalf("Lookup of an absent key = %q, %v, %v", val, found, err)
+	}
+	if _, found, err := s.Lookup([]byte("missing"), []byte("k")); !errors.Is(err, ErrBucketNotFound) || found {
+		t.Fatalf("Lookup of a missing bucket = %v, %v, want ErrBucketNotFound", found, err)
+	}
+}
+
+func TestFindBetweenEx(t *testing.T) {
+	s := newTestStore(t)
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		mustSave(t, s, "b", key, "v")
+	}
+	for _, tc := range []struct {
+		start, end     string
+		startIn, endIn bool
+		want           string
+	}{
+		{"b", "d", true, true, "[b c d]"},
+		{"b", "d", true, false, "[b c]"},
+		{"b", "d", false, true, "[c d]"},
+		{"b", "d", false, false, "[c]"},
+		{"d", "b", false, true, "[b c]"},
+	} {
+		var keys []string
+		if err := s.FindBetweenEx([]byte("b"), []byte(tc.start), []byte(tc.end), tc.startIn, tc.endIn, func(key, val []byte) bool {
+			keys = append(keys, string(key))
+			return true
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(keys); got != tc.want {
+			t.Errorf("FindBetweenEx(%s, %s, %v, %v) = %s, want %s", tc.start, tc.end, tc.startIn, tc.endIn, got, tc.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	s := newTestStore(t)
+	for i := 0; i < 500; i++ {
+		mustSave(t, s, "b", fmt.Sprintf("k%03d", i), "v")
+	}
+	if _, err := s.DeletePrefix([]byte("b"), []byte("k1")); err != nil {
+		t.Fatal(err)
+	}
+	if errs := s.Check(); len(errs) != 0 {
+		t.Fatalf("Check of a healthy store = %v", errs)
+	}
+}
+
+func TestScanRetainedAcrossGoroutines(t *testing.T) {
+	s := newTestStore(t)
+	for i := 0; i < 20; i++ {
+		mustSave(t, s, "b", fmt.Sprintf("k%02d", i), fmt.Sprintf("v%02d", i))
+	}
+	retained := make(chan KV, 20)
+	if err := s.Scan([]byte("b"), func(key, val []byte) bool {
+		retained <- KV{Key: key, Val: val}
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	close(retained)
+
+	// overwrite everything and let another goroutine read the retained pairs
+	for i := 0; i < 20; i++ {
+		mustSave(t, s, "b", fmt.Sprintf("k%02d", i), "overwritten")
+	}
+	done := make(chan error)
+	go func() {
+		for p := range retained {
+			if "v"+string(p.Key[1:]) != string(p.Val) {
+				done <- fmt.Errorf("retained %s=%s", p.Key, p.Val)
+				return
+			}
+		}
+		done <- nil
+	}()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMove(t *testing.T) {
+	s := newTestStore(t)
+	mustSave(t, s, "a", "k", "v")
+	if err := s.CreateBucketIfNotExist([]byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Move([]byte("a"), []byte("k"), []byte("k2")); err != nil {
+		t.Fatal(err)
+	}
+	if got := keysOf(t, s, "a"); got != "[k2]" {
+		t.Fatalf("keys after Move = %s, want [k2]", got)
+	}
+	if err := s.MoveAcross([]byte("a"), []byte("k2"), []byte("b"), []byte("k3")); err != nil {
+		t.Fatal(err)
+	}
+	if got := keysOf(t, s, "a") + keysOf(t, s, "b"); got != "[][k3]" {
+		t.Fatalf("keys after MoveAcross = %s, want [][k3]", got)
+	}
+	if val, err := s.Get([]byte("b"), []byte("k3")); err != nil || string(val) != "v" {
+		t.Fatalf("moved val = %q, %v", val, err)
+	}
+	if err := s.Move([]byte("a"), []byte("missing"), []byte("x")); !errors.Is(err, ErrNotfound) {
+		t.Fatalf("Move of a missing key = %v, want ErrNotfound", err)
+	}
+	if err := s.MoveAcross([]byte("missing"), []byte("k"), []byte("b"), []byte("x")); !errors.Is(err, ErrBucketNotFound) {
+		t.Fatalf("MoveAcross from a missing bucket = %v, want ErrBucketNotFound", err)
+	}
+}
+
+func TestCreateBuckets(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.CreateBuckets([]byte("a"), []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	// existing buckets are kept as they are
+	mustSave(t, s, "a", "k", "v")
+	if err := s.CreateBuckets([]byte("a"), []byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	names, err := s.ListBuckets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprintf("%s", names); got != "[a b c]" {
+		t.Fatalf("ListBuckets = %s, want [a b c]", got)
+	}
+	if got := keysOf(t, s, "a"); got != "[k]" {
+		t.Fatalf("keys of a = %s, want [k]", got)
+	}
+	if err := s.CreateBuckets([]byte("d"), nil); err == nil {
+		t.Fatal("created a bucket with an empty name")
+	}
+	if got, _ := s.ListBuckets(); len(got) != 3 {
+		t.Fatalf("a failed CreateBuckets kept %s", got)
+	}
+}
+
+func TestDeleteIfRacingOwners(t *testing.T) {
+	s := newTestStore(t)
+	bucket, lock := []byte("locks"), []byte("job")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	const owners = 8
+	for round := 0; round < 20; round++ {
+		holder := fmt.Sprintf("owner%d", round%owners)
+		if err := s.Save(bucket, lock, []byte(holder)); err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		released := make(chan string, owners)
+		for o := 0; o < owners; o++ {
+			wg.Add(1)
+			go func(owner string) {
+				defer wg.Done()
+				deleted, err := s.DeleteIf(bucket, lock, []byte(owner))
+				if err != nil {
+					t.Error(err)
+				}
+				if deleted {
+					released <- owner
+				}
+			}(fmt.Sprintf("owner%d", o))
+		}
+		wg.Wait()
+		close(released)
+		var got []string
+		for owner := range released {
+			got = append(got, owner)
+		}
+		if len(got) != 1 || got[0] != holder {
+			t.Fatalf("round %d released by %q, want only %s", round, got, holder)
+		}
+	}
+	if deleted, err := s.DeleteIf(bucket, []byte("absent"), nil); err != nil || deleted {
+		t.Fatalf("DeleteIf of an absent key = %v, %v", deleted, err)
+	}
+}
+
+func TestDeleteBatch(t *testing.T) {
+	s := newTestStore(t)
+	for _, key := range []string{"a", "b", "c"} {
+		mustSave(t, s, "b", key, "v")
+	}
+	keys := [][]byte{[]byte("a"), []byte("absent"), []byte("c"), []byte("a")}
+	if n, err := s.DeleteBatch([]byte("b"), keys); err != nil || n != 2 {
+		t.Fatalf("DeleteBatch = %d, %v, want 2", n, err)
+	}
+	if got := keysOf(t, s, "b"); got != "[b]" {
+		t.Fatalf("keys left = %s, want [b]", got)
+	}
+}
+
+func TestDeleteBatchExpired(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	mustSave(t, s, "b", "live", "v")
+	if err := s.SaveWithTTL(bucket, []byte("expired"), []byte("v"), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := s.Get(bucket, []byte("expired")); !errors.Is(err, ErrNotfound) {
+		t.Fatalf("Get(expired) = %v, want ErrNotfound", err)
+	}
+
+	if n, err := s.DeleteBatch(bucket, [][]byte{[]byte("live"), []byte("expired")}); err != nil || n != 1 {
+		t.Fatalf("DeleteBatch = %d, %v, want only the live key counted", n, err)
+	}
+	if n, err := s.Count(bucket); err != nil || n != 0 {
+		t.Fatalf("Count after DeleteBatch = %d, %v, want the expired key gone too", n, err)
+	}
+}
+
+func TestSaveAutoBucket(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("never-created")
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			if err := s.SaveAutoBucket(bucket, []byte(fmt.Sprint(w)), []byte("v")); err != nil {
+				t.Error(err)
+			}
+		}(w)
+	}
+	wg.Wait()
+	if n, err := s.Count(bucket); err != nil || n != 8 {
+		t.Fatalf("Count = %d, %v, want 8", n, err)
+	}
+}
+
+func TestScanFromResume(t *testing.T) {
+	s := newTestStore(t)
+	for i := 0; i < 25; i++ {
+		mustSave(t, s, "b", fmt.Sprintf("k%02d", i), "v")
+	}
+	var seen []string
+	var after []byte
+	for {
+		n := 0
+		if err := s.ScanFrom([]byte("b"), after, func(key, val []byte) bool {
+			seen = append(seen, string(key))
+			after = key
+			n++
+			return n < 7
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if n < 7 {
+			break
+		}
+	}
+	if len(seen) != 25 {
+		t.Fatalf("resumed scans saw %d keys, want 25: %q", len(seen), seen)
+	}
+	for i, key := range seen {
+		if want := fmt.Sprintf("k%02d", i); key != want {
+			t.Fatalf("key %d = %s, want %s", i, key, want)
+		}
+	}
+}
+
+func TestDeletePreviewCounts(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	for i := 0; i < 20; i++ {
+		mustSave(t, s, "b", fmt.Sprintf("k%02d", i), "v")
+	}
+	if err := s.SaveWithTTL(bucket, []byte("k05x"), []byte("v"), time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateNestedBucket([][]byte{bucket, []byte("k07sub")}); err != nil {
+		t.Fatal(err)
+	}
+
+	preview, err := s.CountBetween(bucket, []byte("k12"), []byte("k03"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted, err := s.DeleteBetween(bucket, []byte("k03"), []byte("k12")); err != nil || deleted != preview {
+		t.Fatalf("DeleteBetween = %d, %v, CountBetween previewed %d", deleted, err, preview)
+	}
+	if preview != 11 {
+		t.Fatalf("CountBetween = %d, want 10 keys and the expired one", preview)
+	}
+
+	if preview, err = s.CountPrefix(bucket, []byte("k1")); err != nil {
+		t.Fatal(err)
+	}
+	if deleted, err := s.DeletePrefix(bucket, []byte("k1")); err != nil || deleted != preview {
+		t.Fatalf("DeletePrefix = %d, %v, CountPrefix previewed %d", deleted, err, preview)
+	}
+}
+
+func TestCloseStopsBackgroundWork(t *testing.T) {
+	before := runtime.NumGoroutine()
+	s, cleanup, err := NewTempStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	s.StartExpiryReaper(time.Millisecond)
+	if _, err := s.StartAutoBackup(t.TempDir(), time.Millisecond, 1); err != nil {
+		t.Fatal(err)
+	}
+	events, _ := s.Watch([]byte("b"))
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := <-events; ok {
+		t.Fatal("Watch channel still open after Close")
+	}
+	if _, err := s.Get([]byte("b"), []byte("k")); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Get after Close = %v, want ErrClosed", err)
+	}
+	if stop := s.StartExpiryReaper(time.Millisecond); stop == nil {
+		t.Fatal("StartExpiryReaper after Close returned a nil stop")
+	}
+	// Close waited for both goroutines, give the runtime a moment to retire them
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after Close, %d before", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTopN(t *testing.T) {
+	s := newTestStore(t)
+	for i := 0; i < 50; i++ {
+		mustSave(t, s, "b", fmt.Sprintf("k%02d", i), fmt.Sprintf("v%02d", i))
+	}
+	keys, vals, err := s.TopN([]byte("b"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 10 || len(vals) != 10 {
+		t.Fatalf("TopN(10) returned %d keys and %d vals", len(keys), len(vals))
+	}
+	for i := range keys {
+		want := 49 - i
+		if string(keys[i]) != fmt.Sprintf("k%02d", want) || string(vals[i]) != fmt.Sprintf("v%02d", want) {
+			t.Fatalf("TopN[%d] = %s=%s, want k%02d", i, keys[i], vals[i], want)
+		}
+	}
+}
+
+func TestValidateEmpty(t *testing.T) {
+	s := newTestStore(t)
+	calls := map[string]func(bucket, key []byte) error{
+		"Save": func(bucket, key []byte) error { return s.Save(bucket, key, []byte("v")) },
+		"Get": func(bucket, key []byte) error {
+			_, err := s.Get(bucket, key)
+			return err
+		},
+		"Delete": s.Delete,
+		"Incr": func(bucket, key []byte) error {
+			_, err := s.Incr(bucket, key)
+			return err
+		},
+	}
+	cases := []struct {
+		bucket, key []byte
+		want        error
+	}{
+		{nil, []byte("k"), ErrEmptyBucket},
+		{[]byte{}, []byte("k"), ErrEmptyBucket},
+		{[]byte("b"), nil, ErrEmptyKey},
+		{[]byte("b"), []byte{}, ErrEmptyKey},
+		{nil, nil, ErrEmptyBucket},
+	}
+	for name, call := range calls {
+		for _, c := range cases {
+			if err := call(c.bucket, c.key); !errors.Is(err, c.want) {
+				t.Errorf("%s(%q, %q) = %v, want %v", name, c.bucket, c.key, err, c.want)
+			}
+		}
+	}
+}
+
+func TestGroups(t *testing.T) {
+	s := newTestStore(t)
+	for _, key := range []string{"user:1", "user:2", "acct:9", "order:1", "order:2", "order:3", "plain"} {
+		mustSave(t, s, "b", key, "v")
+	}
+	groups, err := s.Groups([]byte("b"), []byte(":"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprintf("%s", groups); got != "[acct order user]" {
+		t.Fatalf("Groups = %s, want [acct order user]", got)
+	}
+}
+
+func TestReplaceBucket(t *testing.T) {
+	s := newTestStore(t)
+	for _, key := range []string{"a", "b", "c"} {
+		mustSave(t, s, "b", key, "old")
+	}
+	pairs := []KV{{Key: []byte("b"), Val: []byte("new")}, {Key: []byte("d"), Val: []byte("new")}}
+	if err := s.ReplaceBucket([]byte("b"), pairs); err != nil {
+		t.Fatal(err)
+	}
+	if got := keysOf(t, s, "b"); got != "[b d]" {
+		t.Fatalf("keys after ReplaceBucket = %s, want [b d]", got)
+	}
+	if val, err := s.Get([]byte("b"), []byte("b")); err != nil || string(val) != "new" {
+		t.Fatalf("Get(b) = %q, %v, want new", val, err)
+	}
+}
+
+func TestMergeScan(t *testing.T) {
+	s := newTestStore(t)
+	for _, key := range []string{"a", "c", "e", "g"} {
+		mustSave(t, s, "odd", key, "odd")
+	}
+	for _, key := range []string{"b", "c", "d", "h"} {
+		mustSave(t, s, "even", key, "even")
+	}
+	var got []string
+	err := s.MergeScan([][]byte{[]byte("odd"), []byte("even")}, func(bucket, key, val []byte) bool {
+		got = append(got, fmt.Sprintf("%s/%s", bucket, key))
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[odd/a even/b odd/c even/c even/d odd/e odd/g even/h]"
+	if fmt.Sprint(got) != want {
+		t.Fatalf("MergeScan = %v, want %s", got, want)
+	}
+}
+
+func TestRenameBucket(t *testing.T) {
+	s := newTestStore(t)
+	for _, key := range []string{"a", "b", "c"} {
+		mustSave(t, s, "old", key, "v"+key)
+	}
+	if err := s.SaveNested([][]byte{[]byte("old"), []byte("sub")}, []byte("k"), []byte("nested")); err != nil {
+		t.Fatal(err)
+	}
+	mustSave(t, s, "taken", "k", "v")
+
+	if err := s.RenameBucket([]byte("old"), []byte("taken")); !errors.Is(err, ErrBucketExists) {
+		t.Fatalf("RenameBucket onto an existing bucket = %v, want ErrBucketExists", err)
+	}
+	if err := s.RenameBucket([]byte("missing"), []byte("new")); !errors.Is(err, ErrBucketNotFound) {
+		t.Fatalf("RenameBucket of a missing bucket = %v, want ErrBucketNotFound", err)
+	}
+
+	if err := s.RenameBucket([]byte("old"), []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if got := keysOf(t, s, "new"); got != "[a b c]" {
+		t.Fatalf("keys after RenameBucket = %s, want [a b c]", got)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if val, err := s.Get([]byte("new"), []byte(key)); err != nil || string(val) != "v"+key {
+			t.Fatalf("Get(%s) = %q, %v", key, val, err)
+		}
+	}
+	if val, err := s.GetNested([][]byte{[]byte("new"), []byte("sub")}, []byte("k")); err != nil || string(val) != "nested" {
+		t.Fatalf("nested val after RenameBucket = %q, %v", val, err)
+	}
+	if _, err := s.Get([]byte("old"), []byte("a")); !errors.Is(err, ErrBucketNotFound) {
+		t.Fatalf("Get from the old name = %v, want ErrBucketNotFound", err)
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	s := newTestStore(t)
+	for _, key := range []string{"b", "d", "f"} {
+		mustSave(t, s, "n", key, "v")
+	}
+	cases := []struct{ key, prev, next string }{
+		{"d", "b", "f"},
+		{"b", "", "d"},
+		{"f", "d", ""},
+		{"c", "b", "d"},
+		{"a", "", "b"},
+		{"z", "f", ""},
+	}
+	for _, c := range cases {
+		prev, next, err := s.Neighbors([]byte("n"), []byte(c.key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(prev) != c.prev || string(next) != c.next {
+			t.Errorf("Neighbors(%s) = %q, %q, want %q, %q", c.key, prev, next, c.prev, c.next)
+		}
+		if (c.prev == "") != (prev == nil) || (c.next == "") != (next == nil) {
+			t.Errorf("Neighbors(%s) = %v, %v, want nil at the ends", c.key, prev, next)
+		}
+	}
+}
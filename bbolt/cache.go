@@ -0,0 +1,97 @@
+package db
+
+import (
+	"container/list"
+	"sync"
+)
+
+// CachedStore keeps the most recently read vals of a Store in memory, it is safe for concurrent use.
+// Writes made through it invalidate their key, writes made to the Store directly
+// and TTL expiry do not, so a cached val may be stale until it is evicted.
+type CachedStore struct {
+	s   *Store
+	max int
+
+	mu    sync.Mutex
+	lru   *list.List // of *cacheEntry, most recent first
+	items map[cacheKey]*list.Element
+	// gen counts invalidations, a read that raced one is not cached
+	gen uint64
+}
+
+type cacheKey struct {
+	bucket, key string
+}
+
+type cacheEntry struct {
+	key cacheKey
+	val []byte
+}
+
+// NewCachedStore returns s behind an LRU cache of up to maxEntries vals
+func NewCachedStore(s *Store, maxEntries int) *CachedStore {
+	return &CachedStore{s: s, max: maxEntries, lru: list.New(), items: make(map[cacheKey]*list.Element)}
+}
+
+// Get val by key from bucket, from the cache when it holds it
+func (c *CachedStore) Get(bucket, key []byte) ([]byte, error) {
+	ck := cacheKey{string(bucket), string(key)}
+	c.mu.Lock()
+	if e, ok := c.items[ck]; ok {
+		c.lru.MoveToFront(e)
+		val := clone(e.Value.(*cacheEntry).val)
+		c.mu.Unlock()
+		return val, nil
+	}
+	gen := c.gen
+	c.mu.Unlock()
+
+	val, err := c.s.Get(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	c.add(ck, clone(val), gen)
+	return val, nil
+}
+
+// Save key and val to bucket and drops the cached val
+func (c *CachedStore) Save(bucket, key, val []byte) error {
+	defer c.invalidate(bucket, key)
+	return c.s.Save(bucket, key, val)
+}
+
+// Delete key from bucket and drops the cached val
+func (c *CachedStore) Delete(bucket, key []byte) error {
+	defer c.invalidate(bucket, key)
+	return c.s.Delete(bucket, key)
+}
+
+func (c *CachedStore) add(ck cacheKey, val []byte, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.max <= 0 || gen != c.gen {
+		return
+	}
+	if e, ok := c.items[ck]; ok {
+		e.Value.(*cacheEntry).val = val
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.items[ck] = c.lru.PushFront(&cacheEntry{key: ck, val: val})
+	if c.lru.Len() > c.max {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.items, e.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *CachedStore) invalidate(bucket, key []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	ck := cacheKey{string(bucket), string(key)}
+	if e, ok := c.items[ck]; ok {
+		c.lru.Remove(e)
+		delete(c.items, ck)
+	}
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCachedStore(t *testing.T) {
+	s := newTestStore(t)
+	bucket, key := []byte("b"), []byte("k")
+	mustSave(t, s, "b", "k", "v1")
+	c := NewCachedStore(s, 8)
+
+	if val, err := c.Get(bucket, key); err != nil || string(val) != "v1" {
+		t.Fatalf("Get = %q, %v", val, err)
+	}
+	// a delete behind the cache's back is not seen until the entry goes
+	if err := s.Delete(bucket, key); err != nil {
+		t.Fatal(err)
+	}
+	if val, err := c.Get(bucket, key); err != nil || string(val) != "v1" {
+		t.Fatalf("cached Get after a direct delete = %q, %v, want the cached v1", val, err)
+	}
+
+	if err := c.Save(bucket, key, []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if val, err := c.Get(bucket, key); err != nil || string(val) != "v2" {
+		t.Fatalf("Get after Save = %q, %v, want v2", val, err)
+	}
+	if err := c.Delete(bucket, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(bucket, key); !errors.Is(err, ErrNotfound) {
+		t.Fatalf("Get after Delete = %v, want ErrNotfound", err)
+	}
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// compactTxSize bounds how many bytes one compaction transaction copies before committing
+const compactTxSize = 64 << 20
+
+// Compact copies every bucket and key into a new database file at destPath,
+// leaving out the free pages that make the current file larger than its data
+func (s *Store) Compact(destPath string) error {
+	if s.closed() {
+		return ErrClosed
+	}
+	s.swap.RLock()
+	defer s.swap.RUnlock()
+	return s.compact(destPath)
+}
+
+func (s *Store) compact(destPath string) error {
+	dst, err := bolt.Open(destPath, s.mode, nil)
+	if err != nil {
+		return err
+	}
+	if err = bolt.Compact(dst, s.db, compactTxSize); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// CompactInPlace compacts into a temporary file and renames it over the current one.
+// It waits for open transactions and readers and blocks new ones, including those of
+// the expiry reaper and auto backups, until the file is reopened, so it must not be
+// called from inside Update, View or with a GetReader still open.
+func (s *Store) CompactInPlace() error {
+	if s.readOnly() {
+		return ErrReadOnly
+	}
+	path := s.key
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".compact-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_ = tmp.Close()
+	defer os.Remove(tmpPath)
+	// CreateTemp makes the file 0600 and bbolt keeps the mode of an existing file
+	if err = os.Chmod(tmpPath, s.mode); err != nil {
+		return err
+	}
+
+	s.swap.Lock()
+	defer s.swap.Unlock()
+	if s.closed() {
+		return ErrClosed
+	}
+	if err = s.compact(tmpPath); err != nil {
+		return err
+	}
+	if err = s.db.Close(); err != nil {
+		return err
+	}
+	// reopen whichever file ended up at path, the original one if the rename failed
+	err = os.Rename(tmpPath, path)
+	db, oerr := bolt.Open(path, s.mode, s.opts)
+	if oerr != nil {
+		return oerr
+	}
+	if s.allocSize > 0 {
+		db.AllocSize = s.allocSize
+	}
+	s.db = db
+	return err
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestCompactInPlace(t *testing.T) {
+	s := newTestStore(t, WithAllocSize(1<<20))
+	bucket := []byte("b")
+	val := bytes.Repeat([]byte("x"), 1024)
+	for i := 0; i < 2000; i++ {
+		if err := s.SaveAutoBucket(bucket, []byte(fmt.Sprintf("k%04d", i)), val); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.DeleteBetween(bucket, []byte("k0100"), []byte("k1999")); err != nil {
+		t.Fatal(err)
+	}
+	before := fileSize(t, s.path)
+
+	if err := s.CompactInPlace(); err != nil {
+		t.Fatal(err)
+	}
+	if after := fileSize(t, s.path); after >= before {
+		t.Fatalf("file size %d after compaction, want below %d", after, before)
+	}
+	if n, err := s.Count(bucket); err != nil || n != 100 {
+		t.Fatalf("Count = %d, %v, want 100", n, err)
+	}
+	if got, err := s.Get(bucket, []byte("k0042")); err != nil || !bytes.Equal(got, val) {
+		t.Fatalf("Get after compaction = %d bytes, %v", len(got), err)
+	}
+	if s.db.AllocSize != 1<<20 {
+		t.Fatalf("AllocSize = %d after reopen, want %d", s.db.AllocSize, 1<<20)
+	}
+}
+
+func TestCompactInPlaceKeepsMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mode.db")
+	s, err := NewStore(path, WithFileMode(0640))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	mustSave(t, s, "b", "k", "v")
+	if err := os.Chmod(path, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.CompactInPlace(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0640 {
+		t.Fatalf("mode after compaction = %v, want %v", mode, os.FileMode(0640))
+	}
+}
+
+func TestCompactInPlaceReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ro.db")
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustSave(t, s, "b", "k", "v")
+	_ = s.Close()
+
+	ro, err := NewReadOnlyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if err := ro.CompactInPlace(); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("CompactInPlace = %v, want ErrReadOnly", err)
+	}
+	if val, err := ro.Get([]byte("b"), []byte("k")); err != nil || string(val) != "v" {
+		t.Fatalf("Get = %q, %v", val, err)
+	}
+}
+
+func TestCompactInPlaceConcurrent(t *testing.T) {
+	s := newTestStore(t)
+	stop := s.StartExpiryReaper(time.Millisecond)
+	defer stop()
+	bucket := []byte("b")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	quit := make(chan struct{})
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-quit:
+					return
+				default:
+				}
+				key := []byte(fmt.Sprintf("w%d-%d", w, i%50))
+				if err := s.SaveWithTTL(bucket, key, []byte("v"), time.Millisecond); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := s.Get(bucket, key); err != nil && !errors.Is(err, ErrNotfound) {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+	for i := 0; i < 5; i++ {
+		if err := s.CompactInPlace(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(quit)
+	wg.Wait()
+}
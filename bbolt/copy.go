@@ -0,0 +1,119 @@
+package db
+
+import (
+	"bytes"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// copyEntry is one bucket, when key is nil, or one pair for CopyTo to write
+type copyEntry struct {
+	path     [][]byte
+	seq      uint64
+	key, val []byte
+	expire   int64
+	// raw marks a counter, a plain 8 byte val that Incr reads as is, copied byte for byte
+	raw bool
+}
+
+// CopyTo copies every bucket, nested ones included, with its keys and sequence into dst,
+// creating the buckets it lacks. The source is read in one transaction while dst is written
+// in batches, so dst keeps the batches before a failure.
+// Vals are re-encoded with the options of dst and keep their TTL, expired ones are left out.
+// Plain 8 byte vals are taken for counters and copied as is, since Incr cannot read a framed one.
+// The history of a VersionedStore is copied along with its bucket.
+func (s *Store) CopyTo(dst *Store) error {
+	if dst == s {
+		return nil
+	}
+	return s.View(func(tx *Tx) error {
+		var batch []copyEntry
+		flush := func() error {
+			err := dst.Update(func(dtx *Tx) error {
+				for _, e := range batch {
+					if err := dtx.copyEntry(e); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			batch = batch[:0]
+			return err
+		}
+
+		var walk func(path [][]byte, b *bolt.Bucket) error
+		walk = func(path [][]byte, b *bolt.Bucket) error {
+			batch = append(batch, copyEntry{path: path, seq: b.Sequence()})
+			var subs [][]byte
+			err := b.ForEach(func(k, v []byte) error {
+				if v == nil {
+					subs = append(subs, k)
+					return nil
+				}
+				e := copyEntry{path: path, key: k, val: v, raw: isCounter(v)}
+				if !e.raw {
+					var err error
+					if e.val, e.expire, err = s.decode(v); err != nil {
+						return err
+					}
+					if expired(e.expire, tx.now) {
+						return nil
+					}
+				}
+				if batch = append(batch, e); len(batch) >= importBatchSize {
+					return flush()
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, k := range subs {
+				sub := append(path[:len(path):len(path)], k)
+				if err = walk(sub, b.Bucket(k)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		err := tx.tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return walk([][]byte{name}, b)
+		})
+		if err != nil {
+			return err
+		}
+		return flush()
+	})
+}
+
+func (tx *Tx) copyEntry(e copyEntry) error {
+	b, err := tx.createNested(e.path)
+	if err != nil {
+		return err
+	}
+	if e.key == nil {
+		if e.seq > b.Sequence() {
+			return b.SetSequence(e.seq)
+		}
+		return nil
+	}
+
+	// only top-level buckets are watched
+	var name []byte
+	if len(e.path) == 1 {
+		name = e.path[0]
+	}
+	if !e.raw {
+		return tx.put(name, b, e.key, e.val, e.expire)
+	}
+	if err = b.Put(e.key, e.val); err != nil {
+		return err
+	}
+	return tx.saved(name, e.key, e.val)
+}
+
+// isCounter reports whether v is a plain val with the 8 byte layout of Incr
+func isCounter(v []byte) bool {
+	return len(v) == 8 && !bytes.HasPrefix(v, []byte(valueMagic))
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCopyTo(t *testing.T) {
+	src := newTestStore(t)
+	for i := 0; i < 50; i++ {
+		mustSave(t, src, "a", fmt.Sprintf("k%02d", i), fmt.Sprintf("v%02d", i))
+	}
+	if _, err := src.Incr([]byte("a"), []byte("counter")); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SaveWithTTL([]byte("a"), []byte("ttl"), []byte("v"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SaveNested([][]byte{[]byte("b"), []byte("sub")}, []byte("k"), []byte("nested")); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newTestStore(t, WithCompression(Gzip))
+	if err := src.CopyTo(dst); err != nil {
+		t.Fatal(err)
+	}
+	for _, bucket := range []string{"a", "b"} {
+		var want, got bytes.Buffer
+		if _, err := src.Export([]byte(bucket), &want); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dst.Export([]byte(bucket), &got); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(want.Bytes(), got.Bytes()) {
+			t.Fatalf("bucket %s differs after CopyTo", bucket)
+		}
+	}
+	if n, err := dst.Incr([]byte("a"), []byte("counter")); err != nil || n != 2 {
+		t.Fatalf("Incr of the copied counter = %d, %v, want 2", n, err)
+	}
+	path := [][]byte{[]byte("b"), []byte("sub")}
+	if val, err := dst.GetNested(path, []byte("k")); err != nil || string(val) != "nested" {
+		t.Fatalf("copied nested val = %q, %v", val, err)
+	}
+}
+
+func TestCopyToEncodesPlainVals(t *testing.T) {
+	src := newTestStore(t)
+	mustSave(t, src, "a", "secret", "SECRETSECRET")
+	if _, err := src.Incr([]byte("a"), []byte("counter")); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newTestStore(t, WithEncryption(make([]byte, 32)), WithChecksum())
+	if err := src.CopyTo(dst); err != nil {
+		t.Fatal(err)
+	}
+	err := dst.View(func(tx *Tx) error {
+		if raw := tx.tx.Bucket([]byte("a")).Get([]byte("secret")); bytes.Contains(raw, []byte("SECRET")) {
+			t.Fatalf("copied val stored in plaintext: %q", raw)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, err := dst.Get([]byte("a"), []byte("secret")); err != nil || string(val) != "SECRETSECRET" {
+		t.Fatalf("Get of the copied val = %q, %v", val, err)
+	}
+	if n, err := dst.Incr([]byte("a"), []byte("counter")); err != nil || n != 2 {
+		t.Fatalf("Incr of the copied counter = %d, %v, want 2", n, err)
+	}
+}
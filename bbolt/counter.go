@@ -0,0 +1,73 @@
+package db
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	ErrIDsExhausted = errors.New("namespace ids are exhausted")
+)
+
+// Counter is a number stored at one key with the same layout as Incr
+type Counter struct {
+	s           *Store
+	bucket, key []byte
+}
+
+// Counter returns the counter stored at key in bucket
+func (s *Store) Counter(bucket, key []byte) *Counter {
+	return &Counter{s: s, bucket: bucket, key: key}
+}
+
+// Inc increase the counter by one
+func (c *Counter) Inc() (uint64, error) {
+	return c.s.Incr(c.bucket, c.key)
+}
+
+// Add n to the counter, see Store.IncrBy
+func (c *Counter) Add(n int64) (uint64, error) {
+	return c.s.IncrBy(c.bucket, c.key, n)
+}
+
+// Value returns the current count, zero if it was never set
+func (c *Counter) Value() (n uint64, err error) {
+	err = c.s.View(func(tx *Tx) error {
+		b, err := tx.bucket(c.bucket)
+		if err != nil {
+			return err
+		}
+		n, err = number(b.Get(c.key))
+		return err
+	})
+	return
+}
+
+// Reset the counter to zero
+func (c *Counter) Reset() error {
+	return c.s.Delete(c.bucket, c.key)
+}
+
+// NextID returns the next id of namespace in bucket starting at 1, each namespace counts
+// on its own in a key with the layout of Incr. ErrIDsExhausted once math.MaxUint64 is taken.
+func (s *Store) NextID(bucket, namespace []byte) (id uint64, err error) {
+	if err = validate(bucket, namespace); err != nil {
+		return
+	}
+	err = s.Update(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		// Incr would stop at the max and hand it out again
+		if n, err := number(b.Get(namespace)); err != nil || n == math.MaxUint64 {
+			if err == nil {
+				err = ErrIDsExhausted
+			}
+			return err
+		}
+		id, err = tx.Incr(bucket, namespace)
+		return err
+	})
+	return
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestCounterIncrInterop(t *testing.T) {
+	s := newTestStore(t)
+	bucket, key := []byte("b"), []byte("hits")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	c := s.Counter(bucket, key)
+	if n, err := c.Value(); err != nil || n != 0 {
+		t.Fatalf("Value of a new counter = %d, %v, want 0", n, err)
+	}
+	if _, err := s.Incr(bucket, key); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.Inc(); err != nil || n != 2 {
+		t.Fatalf("Inc after Incr = %d, %v, want 2", n, err)
+	}
+	if n, err := c.Add(5); err != nil || n != 7 {
+		t.Fatalf("Add = %d, %v, want 7", n, err)
+	}
+	if n, err := s.Decr(bucket, key); err != nil || n != 6 {
+		t.Fatalf("Decr after Add = %d, %v, want 6", n, err)
+	}
+	if err := c.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.Incr(bucket, key); err != nil || n != 1 {
+		t.Fatalf("Incr after Reset = %d, %v, want 1", n, err)
+	}
+}
+
+func TestNextIDConcurrent(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("ids")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	const workers, each = 8, 25
+	var mu sync.Mutex
+	seen := map[string]map[uint64]bool{"users": {}, "orders": {}}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		ns := "users"
+		if w%2 == 1 {
+			ns = "orders"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				id, err := s.NextID(bucket, []byte(ns))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				if seen[ns][id] {
+					t.Errorf("%s id %d handed out twice", ns, id)
+				}
+				seen[ns][id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	for ns, ids := range seen {
+		for id := uint64(1); id <= workers/2*each; id++ {
+			if !ids[id] {
+				t.Fatalf("%s is missing id %d", ns, id)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"bytes"
+	"time"
+)
+
+// FindGlob find val by keys of bucket matching pattern, where * matches any run of bytes and
+// ? any single byte. Only keys with the literal prefix before the first wildcard are visited,
+// so a pattern starting with a wildcard scans the whole bucket.
+func (s *Store) FindGlob(bucket, pattern []byte, next func(key, val []byte) bool) (err error) {
+	defer s.observe("FindGlob", time.Now(), &err)
+	prefix := pattern
+	if i := bytes.IndexAny(pattern, "*?"); i >= 0 {
+		prefix = pattern[:i]
+	}
+	return ignoreStop(s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		c := b.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if !globMatch(pattern[len(prefix):], k[len(prefix):]) {
+				continue
+			}
+			val, ok, err := s.value(v, tx.now)
+			if err != nil {
+				return err
+			}
+			if ok && !next(clone(k), val) {
+				return errStop
+			}
+		}
+		return nil
+	}))
+}
+
+// globMatch reports whether all of name matches pattern, backtracking to the last * only
+func globMatch(pattern, name []byte) bool {
+	p, n := 0, 0
+	star, mark := -1, 0
+	for n < len(name) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == name[n]):
+			p++
+			n++
+		case p < len(pattern) && pattern[p] == '*':
+			star, mark = p, n
+			p++
+		case star >= 0:
+			// let the last * take one more byte
+			mark++
+			p, n = star+1, mark
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
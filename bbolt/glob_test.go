@@ -0,0 +1,34 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFindGlob(t *testing.T) {
+	s := newTestStore(t)
+	for _, key := range []string{"log-2024-01.txt", "log-2024-02.txt", "log-2024-02.gz", "log-2025-01.txt", "note.txt", "xlog-2024-01.txt"} {
+		mustSave(t, s, "b", key, "v")
+	}
+	cases := map[string]string{
+		"log-*.txt":       "[log-2024-01.txt log-2024-02.txt log-2025-01.txt]",
+		"log-2024-0?.txt": "[log-2024-01.txt log-2024-02.txt]",
+		"log-*-01.*":      "[log-2024-01.txt log-2025-01.txt]",
+		"*log-2024-01*":   "[log-2024-01.txt xlog-2024-01.txt]",
+		"note.txt":        "[note.txt]",
+		"log":             "[]",
+	}
+	for pattern, want := range cases {
+		var got []string
+		err := s.FindGlob([]byte("b"), []byte(pattern), func(key, val []byte) bool {
+			got = append(got, string(key))
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != want {
+			t.Errorf("FindGlob(%s) = %v, want %s", pattern, got, want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// newTestStore returns a store on a temporary file that is removed when t finishes
+func newTestStore(t testing.TB, opts ...Option) *Store {
+	t.Helper()
+	s, cleanup, err := NewTempStore(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	return s
+}
+
+// mustSave saves val under key in bucket, creating the bucket if needed
+func mustSave(t testing.TB, s *Store, bucket, key, val string) {
+	t.Helper()
+	if err := s.SaveAutoBucket([]byte(bucket), []byte(key), []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewTempStore(t *testing.T) {
+	s, cleanup, err := NewTempStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := s.path
+	if err := s.CreateBucketIfNotExist([]byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	cleanup()
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("file still there after cleanup: %v", err)
+	}
+	if _, err := s.Get([]byte("b"), []byte("k")); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Get after cleanup = %v, want ErrClosed", err)
+	}
+}
+
+// keysOf returns the keys of bucket formatted like [a b c]
+func keysOf(t testing.TB, s *Store, bucket string) string {
+	t.Helper()
+	var keys []string
+	if err := s.ScanKeys([]byte(bucket), func(key []byte) bool {
+		keys = append(keys, string(key))
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprint(keys)
+}
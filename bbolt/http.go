@@ -0,0 +1,113 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Handler exposes s over HTTP:
+//
+//	GET    /{bucket}/{key}        the raw val
+//	PUT    /{bucket}/{key}        save the request body as val
+//	DELETE /{bucket}/{key}        delete the key
+//	GET    /{bucket}?prefix=...   JSON list of KV with keys having prefix
+//
+// Missing keys are 404, missing buckets are 400, a body over WithMaxValueSize is 413
+// and writes to a read-only store are 403.
+func Handler(s *Store) http.Handler {
+	return &handler{s: s}
+}
+
+type handler struct {
+	s *Store
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, hasKey := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket == "" || (hasKey && key == "") {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !hasKey {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		h.list(w, []byte(bucket), []byte(r.URL.Query().Get("prefix")))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		val, err := h.s.Get([]byte(bucket), []byte(key))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(val)
+	case http.MethodPut:
+		body := r.Body
+		if max := h.s.maxValSize; max > 0 {
+			body = http.MaxBytesReader(w, body, int64(max))
+		}
+		val, err := io.ReadAll(body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, ErrValueTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := h.s.Save([]byte(bucket), []byte(key), val); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if err := h.s.Delete([]byte(bucket), []byte(key)); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *handler) list(w http.ResponseWriter, bucket, prefix []byte) {
+	pairs := []KV{}
+	err := h.s.FindPrefix(bucket, prefix, func(key, val []byte) bool {
+		pairs = append(pairs, KV{Key: key, Val: val})
+		return true
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(pairs)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch err {
+	case ErrNotfound:
+		code = http.StatusNotFound
+	case ErrBucketNotFound, ErrEmptyBucket, ErrEmptyKey:
+		code = http.StatusBadRequest
+	case ErrValueTooLarge:
+		code = http.StatusRequestEntityTooLarge
+	case ErrReadOnly:
+		code = http.StatusForbidden
+	}
+	http.Error(w, err.Error(), code)
+}
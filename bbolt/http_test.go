@@ -0,0 +1,102 @@
+package db
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// do sends one request to h and returns the response code and body
+func do(t *testing.T, h http.Handler, method, target, body string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+func TestHandlerMaxValueSize(t *testing.T) {
+	s := newTestStore(t, WithMaxValueSize(4))
+	if err := s.CreateBucketIfNotExist([]byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	h := Handler(s)
+	if code, _ := do(t, h, http.MethodPut, "/b/k", "12345"); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("PUT over the limit = %d, want 413", code)
+	}
+	if code, _ := do(t, h, http.MethodPut, "/b/k", "1234"); code != http.StatusNoContent {
+		t.Fatalf("PUT within the limit = %d, want 204", code)
+	}
+}
+
+func TestHandlerReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ro.db")
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustSave(t, s, "b", "k", "v")
+	_ = s.Close()
+	ro, err := NewReadOnlyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+
+	h := Handler(ro)
+	if code, _ := do(t, h, http.MethodPut, "/b/k", "new"); code != http.StatusForbidden {
+		t.Fatalf("PUT = %d, want 403", code)
+	}
+	if code, body := do(t, h, http.MethodGet, "/b/k", ""); code != http.StatusOK || body != "v" {
+		t.Fatalf("GET = %d %q", code, body)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.CreateBucketIfNotExist([]byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	h := Handler(s)
+
+	for _, step := range []struct {
+		method, target, body string
+		code                 int
+		want                 string
+	}{
+		{http.MethodGet, "/b/k", "", http.StatusNotFound, ""},
+		{http.MethodPut, "/b/k", "v1", http.StatusNoContent, ""},
+		{http.MethodPut, "/b/k2", "v2", http.StatusNoContent, ""},
+		{http.MethodPut, "/b/x", "v3", http.StatusNoContent, ""},
+		{http.MethodGet, "/b/k", "", http.StatusOK, "v1"},
+		{http.MethodDelete, "/b/x", "", http.StatusNoContent, ""},
+		{http.MethodGet, "/b/x", "", http.StatusNotFound, ""},
+		{http.MethodGet, "/missing/k", "", http.StatusBadRequest, ""},
+		{http.MethodPost, "/b/k", "", http.StatusMethodNotAllowed, ""},
+		{http.MethodPut, "/b", "", http.StatusMethodNotAllowed, ""},
+		{http.MethodGet, "/", "", http.StatusNotFound, ""},
+	} {
+		code, body := do(t, h, step.method, step.target, step.body)
+		if code != step.code || (step.want != "" && body != step.want) {
+			t.Fatalf("%s %s = %d %q, want %d %q", step.method, step.target, code, body, step.code, step.want)
+		}
+	}
+
+	code, body := do(t, h, http.MethodGet, "/b?prefix=k", "")
+	if code != http.StatusOK {
+		t.Fatalf("GET /b?prefix=k = %d %q", code, body)
+	}
+	var pairs []KV
+	if err := json.Unmarshal([]byte(body), &pairs); err != nil {
+		t.Fatal(err)
+	}
+	if len(pairs) != 2 || string(pairs[0].Key) != "k" || string(pairs[1].Val) != "v2" {
+		t.Fatalf("prefix list = %q", pairs)
+	}
+	if _, body := do(t, h, http.MethodGet, "/b?prefix=none", ""); strings.TrimSpace(body) != "[]" {
+		t.Fatalf("empty prefix list = %q, want []", body)
+	}
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"bytes"
+)
+
+// Index keeps indexBucket mapping an attribute of each record to its id in dataBucket.
+// Attributes are unique, a Put with an attribute held by another id takes it over.
+type Index struct {
+	s           *Store
+	data, index []byte
+	keyFn       func(val []byte) ([]byte, error)
+}
+
+// Index returns the index over dataBucket, keyFn derives the indexed attribute from a record val.
+// Both buckets must exist.
+func (s *Store) Index(dataBucket, indexBucket []byte, keyFn func(val []byte) ([]byte, error)) *Index {
+	return &Index{s: s, data: dataBucket, index: indexBucket, keyFn: keyFn}
+}
+
+// Put saves val at id and points its attribute at id in the same transaction
+func (x *Index) Put(id, val []byte) error {
+	attr, err := x.keyFn(val)
+	if err != nil {
+		return err
+	}
+	return x.s.Update(func(tx *Tx) error {
+		if err := x.unindex(tx, id, attr); err != nil {
+			return err
+		}
+		if err := tx.Save(x.data, id, val); err != nil {
+			return err
+		}
+		return tx.Save(x.index, attr, id)
+	})
+}
+
+// Lookup returns the id and val of the record with attr, ErrNotfound if there is none
+func (x *Index) Lookup(attr []byte) (id, val []byte, err error) {
+	err = x.s.View(func(tx *Tx) error {
+		if id, err = tx.Get(x.index, attr); err != nil {
+			return err
+		}
+		if val, err = tx.Get(x.data, id); err != nil {
+			return err
+		}
+		// an entry whose record is gone or changed, e.g. by expiry or a write around the index
+		cur, err := x.keyFn(val)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(cur, attr) {
+			return ErrNotfound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return
+}
+
+// Delete the record at id together with its index entry
+func (x *Index) Delete(id []byte) error {
+	return x.s.Update(func(tx *Tx) error {
+		if err := x.unindex(tx, id, nil); err != nil {
+			return err
+		}
+		return tx.Delete(x.data, id)
+	})
+}
+
+// unindex removes the entry of the current record at id unless its attribute is keep
+func (x *Index) unindex(tx *Tx, id, keep []byte) error {
+	old, err := tx.Get(x.data, id)
+	if err == ErrNotfound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	attr, err := x.keyFn(old)
+	if err != nil {
+		return err
+	}
+	if keep != nil && bytes.Equal(attr, keep) {
+		return nil
+	}
+	// leave the entry alone when another record has taken the attribute over
+	if owner, err := tx.Get(x.index, attr); err != nil || !bytes.Equal(owner, id) {
+		if err == ErrNotfound {
+			err = nil
+		}
+		return err
+	}
+	return tx.Delete(x.index, attr)
+}
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// emailOf indexes records of the form "name|email" by email
+func emailOf(val []byte) ([]byte, error) {
+	_, email, ok := bytes.Cut(val, []byte("|"))
+	if !ok {
+		return nil, errors.New("record has no email")
+	}
+	return email, nil
+}
+
+func TestIndex(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.CreateBuckets([]byte("users"), []byte("by-email")); err != nil {
+		t.Fatal(err)
+	}
+	x := s.Index([]byte("users"), []byte("by-email"), emailOf)
+	if err := x.Put([]byte("1"), []byte("ada|ada@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	if id, val, err := x.Lookup([]byte("ada@example.com")); err != nil || string(id) != "1" || string(val) != "ada|ada@example.com" {
+		t.Fatalf("Lookup = %q, %q, %v", id, val, err)
+	}
+
+	// changing the attribute moves the entry
+	if err := x.Put([]byte("1"), []byte("ada|lovelace@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := x.Lookup([]byte("ada@example.com")); !errors.Is(err, ErrNotfound) {
+		t.Fatalf("Lookup of the old attribute = %v, want ErrNotfound", err)
+	}
+	if id, _, err := x.Lookup([]byte("lovelace@example.com")); err != nil || string(id) != "1" {
+		t.Fatalf("Lookup of the new attribute = %q, %v", id, err)
+	}
+	if got := keysOf(t, s, "by-email"); got != "[lovelace@example.com]" {
+		t.Fatalf("index keys = %s", got)
+	}
+	if _, _, err := x.Lookup([]byte("nobody@example.com")); !errors.Is(err, ErrNotfound) {
+		t.Fatalf("Lookup of an unknown attribute = %v, want ErrNotfound", err)
+	}
+}
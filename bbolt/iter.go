@@ -0,0 +1,23 @@
+package db
+
+import (
+	"iter"
+)
+
+// All returns an iterator over the keys and vals of bucket for range-over-func, see Scan.
+// The read transaction stays open for the whole loop. Iteration ends early on an error,
+// such as a missing bucket, which is dropped, use AllErr to get it.
+func (s *Store) All(bucket []byte) iter.Seq2[[]byte, []byte] {
+	return s.AllErr(bucket, nil)
+}
+
+// AllErr is like All but stores the error that ended the iteration in *errp once
+// the loop is done, nil errp discards it. Breaking out of the loop is not an error.
+func (s *Store) AllErr(bucket []byte, errp *error) iter.Seq2[[]byte, []byte] {
+	return func(yield func(key, val []byte) bool) {
+		err := s.Scan(bucket, yield)
+		if errp != nil {
+			*errp = err
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	s := newTestStore(t)
+	for i := 0; i < 5; i++ {
+		mustSave(t, s, "b", fmt.Sprintf("k%d", i), fmt.Sprintf("v%d", i))
+	}
+
+	var err error
+	var keys []string
+	for key, val := range s.AllErr([]byte("b"), &err) {
+		if want := "v" + string(key[1:]); string(val) != want {
+			t.Fatalf("val of %s = %q, want %q", key, val, want)
+		}
+		keys = append(keys, string(key))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(keys); got != "[k0 k1 k2 k3 k4]" {
+		t.Fatalf("keys = %s", got)
+	}
+}
+
+func TestAllBreak(t *testing.T) {
+	s := newTestStore(t)
+	for i := 0; i < 5; i++ {
+		mustSave(t, s, "b", fmt.Sprintf("k%d", i), "v")
+	}
+
+	var err error
+	n := 0
+	for range s.AllErr([]byte("b"), &err) {
+		if n++; n == 2 {
+			break
+		}
+	}
+	if err != nil || n != 2 {
+		t.Fatalf("visited %d before break, err %v", n, err)
+	}
+	// the read transaction was released, a write does not block
+	mustSave(t, s, "b", "after", "v")
+}
+
+func TestAllMissingBucket(t *testing.T) {
+	s := newTestStore(t)
+	var err error
+	for range s.AllErr([]byte("missing"), &err) {
+		t.Fatal("yielded from a missing bucket")
+	}
+	if !errors.Is(err, ErrBucketNotFound) {
+		t.Fatalf("err = %v, want ErrBucketNotFound", err)
+	}
+	for range s.All([]byte("missing")) {
+		t.Fatal("yielded from a missing bucket")
+	}
+}
+
+func TestAllWithoutErr(t *testing.T) {
+	s := newTestStore(t)
+	mustSave(t, s, "b", "k", "v")
+	n := 0
+	for key, val := range s.All([]byte("b")) {
+		if string(key) != "k" || string(val) != "v" {
+			t.Fatalf("yielded %s=%s", key, val)
+		}
+		n++
+	}
+	if n != 1 {
+		t.Fatalf("visited %d, want 1", n)
+	}
+}
@@ -0,0 +1,33 @@
+package db
+
+import (
+	"encoding/json"
+)
+
+// SaveJSON save v to bucket encoded as JSON
+func SaveJSON[T any](s *Store, bucket, key []byte, v T) error {
+	val, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.Save(bucket, key, val)
+}
+
+// GetJSON get the JSON val by key from bucket and decode it into a T,
+// store errors such as ErrNotfound are returned as is
+func GetJSON[T any](s *Store, bucket, key []byte) (v T, err error) {
+	val, err := s.Get(bucket, key)
+	if err != nil {
+		return v, err
+	}
+	err = json.Unmarshal(val, &v)
+	return v, err
+}
+
+// JSON is the Codec backed by encoding/json
+var JSON Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
@@ -0,0 +1,27 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+type point struct {
+	X, Y int
+}
+
+func TestSaveGetJSON(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveJSON(s, bucket, []byte("p"), point{X: 1, Y: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := GetJSON[point](s, bucket, []byte("p")); err != nil || p != (point{X: 1, Y: 2}) {
+		t.Fatalf("GetJSON = %+v, %v", p, err)
+	}
+	if _, err := GetJSON[point](s, bucket, []byte("missing")); !errors.Is(err, ErrNotfound) {
+		t.Fatalf("GetJSON of a missing key = %v, want ErrNotfound", err)
+	}
+}
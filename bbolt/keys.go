@@ -0,0 +1,35 @@
+package db
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrInvalidKey = errors.New("key is not an 8-byte number")
+)
+
+// Uint64Key returns n as an 8-byte big-endian key, so keys sort in numeric order
+func Uint64Key(n uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, n)
+	return key
+}
+
+// KeyUint64 returns the number in a key made by Uint64Key
+func KeyUint64(key []byte) (uint64, error) {
+	if len(key) != 8 {
+		return 0, ErrInvalidKey
+	}
+	return binary.BigEndian.Uint64(key), nil
+}
+
+// SaveUint64 save val to bucket at the key for n
+func (s *Store) SaveUint64(bucket []byte, n uint64, val []byte) error {
+	return s.Save(bucket, Uint64Key(n), val)
+}
+
+// GetUint64 get val by the key for n from bucket
+func (s *Store) GetUint64(bucket []byte, n uint64) ([]byte, error) {
+	return s.Get(bucket, Uint64Key(n))
+}
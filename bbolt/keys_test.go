@@ -0,0 +1,42 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestUint64KeyOrder(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []uint64{math.MaxUint64, 256, 2, 1} {
+		if err := s.SaveUint64(bucket, n, []byte(fmt.Sprint(n))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []uint64
+	err := s.FindBetween(bucket, Uint64Key(1), Uint64Key(math.MaxUint64), func(key, val []byte) bool {
+		n, err := KeyUint64(key)
+		if err != nil {
+			t.Error(err)
+		}
+		got = append(got, n)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint([]uint64{1, 2, 256, math.MaxUint64}) {
+		t.Fatalf("key order = %v", got)
+	}
+	if val, err := s.GetUint64(bucket, 256); err != nil || string(val) != "256" {
+		t.Fatalf("GetUint64(256) = %q, %v", val, err)
+	}
+	if _, err := KeyUint64([]byte("short")); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("KeyUint64 of a short key = %v, want ErrInvalidKey", err)
+	}
+}
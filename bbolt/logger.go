@@ -0,0 +1,18 @@
+package db
+
+// Logger receives warnings from the store's background work such as the expiry reaper
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+// WithLogger sends the store's warnings to l instead of discarding them
+func WithLogger(l Logger) Option {
+	return func(s *Store) error {
+		s.logger = l
+		return nil
+	}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(string, ...any) {}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// captureLogger records every line the store logs
+type captureLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *captureLogger) Printf(format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *captureLogger) Lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.lines...)
+}
+
+func TestLoggerAutoBackupFailure(t *testing.T) {
+	logger := &captureLogger{}
+	s := newTestStore(t, WithLogger(logger))
+	dir := filepath.Join(t.TempDir(), "backups")
+	stop, err := s.StartAutoBackup(dir, 5*time.Millisecond, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	// backups can no longer be written once dir is a file
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		lines := logger.Lines()
+		if len(lines) > 0 {
+			if !strings.Contains(lines[0], "auto-backup") {
+				t.Fatalf("logged %q, want an auto-backup failure", lines[0])
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the failed backup was not logged")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	bolt "go.etcd.io/bbolt"
+)
+
+// CreateNestedBucket create every bucket along path if not exist
+func (s *Store) CreateNestedBucket(path [][]byte) error {
+	return s.Update(func(tx *Tx) error {
+		_, err := tx.createNested(path)
+		return err
+	})
+}
+
+// SaveNested save key and val to the bucket at path, creating the buckets along it as needed
+func (s *Store) SaveNested(path [][]byte, key, val []byte) error {
+	return s.Update(func(tx *Tx) error {
+		b, err := tx.createNested(path)
+		if err != nil {
+			return err
+		}
+		return tx.put(nil, b, key, val, 0)
+	})
+}
+
+// GetNested get val by key from the bucket at path
+func (s *Store) GetNested(path [][]byte, key []byte) (val []byte, err error) {
+	err = s.View(func(tx *Tx) error {
+		b, err := tx.nested(path)
+		if err != nil {
+			return err
+		}
+		var ok bool
+		val, ok, err = s.get(b, key, tx.now)
+		if err == nil && !ok {
+			err = ErrNotfound
+		}
+		return err
+	})
+	return
+}
+
+// nested walks path from the top-level bucket down, any missing bucket is ErrBucketNotFound
+func (tx *Tx) nested(path [][]byte) (*bolt.Bucket, error) {
+	if len(path) == 0 {
+		return nil, ErrBucketNotFound
+	}
+	b, err := tx.bucket(path[0])
+	for _, name := range path[1:] {
+		if err != nil {
+			break
+		}
+		if b = b.Bucket(name); b == nil {
+			err = ErrBucketNotFound
+		}
+	}
+	return b, err
+}
+
+// createNested walks path from the top-level bucket down, creating missing buckets
+func (tx *Tx) createNested(path [][]byte) (*bolt.Bucket, error) {
+	if len(path) == 0 {
+		return nil, bolt.ErrBucketNameRequired
+	}
+	b, err := tx.tx.CreateBucketIfNotExists(path[0])
+	for _, name := range path[1:] {
+		if err != nil {
+			break
+		}
+		b, err = b.CreateBucketIfNotExists(name)
+	}
+	return b, err
+}
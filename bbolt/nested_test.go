@@ -0,0 +1,31 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNestedBuckets(t *testing.T) {
+	s := newTestStore(t)
+	path := [][]byte{[]byte("users"), []byte("42")}
+	if err := s.CreateNestedBucket(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveNested(path, []byte("name"), []byte("ada")); err != nil {
+		t.Fatal(err)
+	}
+	if val, err := s.GetNested(path, []byte("name")); err != nil || string(val) != "ada" {
+		t.Fatalf("GetNested = %q, %v", val, err)
+	}
+	if _, err := s.GetNested(path, []byte("missing")); !errors.Is(err, ErrNotfound) {
+		t.Fatalf("GetNested of a missing key = %v, want ErrNotfound", err)
+	}
+	other := [][]byte{[]byte("users"), []byte("7")}
+	if _, err := s.GetNested(other, []byte("name")); !errors.Is(err, ErrBucketNotFound) {
+		t.Fatalf("GetNested of a missing bucket = %v, want ErrBucketNotFound", err)
+	}
+	// the nested bucket is not a key of its parent
+	if got := keysOf(t, s, "users"); got != "[]" {
+		t.Fatalf("keys of users = %s, want none", got)
+	}
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"io"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Option configures a Store when it is opened, an error aborts the open
+type Option func(*Store) error
+
+// WithBoltOptions opens the database with bbolt options, nil uses the defaults
+func WithBoltOptions(opts *bolt.Options) Option {
+	return func(s *Store) error {
+		s.opts = opts
+		return nil
+	}
+}
+
+// WithInitialMmapSize maps size bytes of the file up front. Growing past the mapping
+// remaps it, which waits for every open read transaction, so pre-sizing for a bulk load
+// keeps writers from stalling behind long readers.
+func WithInitialMmapSize(size int) Option {
+	return func(s *Store) error {
+		s.boltOptions().InitialMmapSize = size
+		return nil
+	}
+}
+
+// WithAllocSize grows the file by size bytes at a time instead of bbolt's DefaultAllocSize
+func WithAllocSize(size int) Option {
+	return func(s *Store) error {
+		s.allocSize = size
+		return nil
+	}
+}
+
+// WithChecksum stores a CRC32 with every val saved from now on and reads fail with
+// ErrChecksumMismatch when it does not match. Vals saved before stay readable unchecked,
+// and counters written by Incr are exempt since they are kept as plain 8-byte numbers.
+func WithChecksum() Option {
+	return func(s *Store) error {
+		s.checksum = true
+		return nil
+	}
+}
+
+// WithMaxValueSize makes every write of a val longer than size bytes fail with ErrValueTooLarge,
+// zero means no limit. It applies to the val as given, before compression or encryption.
+func WithMaxValueSize(size int) Option {
+	return func(s *Store) error {
+		s.maxValSize = size
+		return nil
+	}
+}
+
+// SaveHook runs inside the transaction of every val written to a top-level bucket, by Save,
+// Incr, Move, CopyTo and the rest alike, counters pass their 8-byte big-endian val.
+// RenameBucket moves vals without running hooks and vals in nested buckets never do.
+// An error from a hook rolls the whole transaction back.
+type SaveHook func(tx *Tx, bucket, key, val []byte) error
+
+// WithOnSave registers hooks that run in order after each val is written, see SaveHook.
+// Hooks see the writes they make through tx as well, so they should check bucket.
+func WithOnSave(hooks ...SaveHook) Option {
+	return func(s *Store) error {
+		s.onSave = append(s.onSave, hooks...)
+		return nil
+	}
+}
+
+// ObserverFunc is told the name, duration and error of each observed store call
+type ObserverFunc func(op string, d time.Duration, err error)
+
+// WithObserver calls fn after each call of Save, Get, Delete, Incr, Scan, ScanContext, ScanKeys,
+// ScanReverse, ScanFrom, MergeScan, FindPrefix, FindSuffix, FindBetween, FindGlob,
+// LastWithPrefix and Page, named as listed. Incr, Decr and IncrBy report as Incr,
+// FindPrefixLimit as FindPrefix and FindBetweenEx as FindBetween. Scans and finds
+// include the time spent in their callback. Methods not listed are not observed.
+func WithObserver(fn ObserverFunc) Option {
+	return func(s *Store) error {
+		s.observer = fn
+		return nil
+	}
+}
+
+// WithFileMode creates the database file with mode instead of 0600
+func WithFileMode(mode os.FileMode) Option {
+	return func(s *Store) error {
+		s.mode = mode
+		return nil
+	}
+}
+
+// WithMkdirAll creates the parent directories of the database file if they are missing
+func WithMkdirAll() Option {
+	return func(s *Store) error {
+		s.mkdir = true
+		return nil
+	}
+}
+
+// WithCompression compresses vals with c on save, vals that do not shrink are kept as they are.
+// Vals saved without compression stay readable and counters are never compressed.
+func WithCompression(c Compressor) Option {
+	return func(s *Store) error {
+		s.compressor = c
+		return nil
+	}
+}
+
+// WithEncryption encrypts vals with AES-GCM under key, which must be 16, 24 or 32 bytes.
+// Keys stay plaintext so ordering and prefix search still work, counters are not encrypted.
+// A val that fails authentication, from a wrong key or tampering, reads as ErrDecrypt.
+func WithEncryption(key []byte) Option {
+	return func(s *Store) error {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return err
+		}
+		s.aead, err = cipher.NewGCM(block)
+		s.keyID = sha256.Sum256(key)
+		return err
+	}
+}
+
+func (s *Store) observe(op string, start time.Time, err *error) {
+	if s.observer != nil {
+		s.observer(op, time.Since(start), *err)
+	}
+}
+
+// boltOptions returns the bbolt options to open with, set up for options to change
+func (s *Store) boltOptions() *bolt.Options {
+	o := bolt.Options{}
+	if s.opts != nil {
+		o = *s.opts
+	}
+	s.opts = &o
+	return s.opts
+}
+
+// Compressor compresses vals before they are written
+type Compressor interface {
+	Compress(data []byte) []byte
+	Decompress(data []byte) ([]byte, error)
+}
+
+// Gzip is the Compressor backed by compress/gzip
+var Gzip Compressor = gzipCompressor{}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Compress(data []byte) []byte {
+	var buf bytes.Buffer
+	// writing to a bytes.Buffer does not fail
+	w := gzip.NewWriter(&buf)
+	_, _ = w.Write(data)
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countHook returns a SaveHook counting the vals saved to bucket in the counts bucket
+func countHook(bucket string) SaveHook {
+	return func(tx *Tx, b, key, val []byte) error {
+		if string(b) != bucket {
+			return nil
+		}
+		_, err := tx.IncrBy([]byte("counts"), []byte(bucket), 1)
+		return err
+	}
+}
+
+func TestOnSaveCounts(t *testing.T) {
+	s := newTestStore(t, WithOnSave(countHook("b")))
+	if err := s.CreateBuckets([]byte("b"), []byte("other"), []byte("counts")); err != nil {
+		t.Fatal(err)
+	}
+	mustSave(t, s, "other", "k", "v")
+	bucket := []byte("b")
+	for _, write := range []func() error{
+		func() error { return s.Save(bucket, []byte("k1"), []byte("v")) },
+		func() error { _, err := s.Incr(bucket, []byte("n")); return err },
+		func() error { return s.MoveAcross([]byte("other"), []byte("k"), bucket, []byte("k2")) },
+	} {
+		if err := write(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the MoveAcross source was saved to other, which the hook does not count
+	if n, err := s.Counter([]byte("counts"), []byte("b")).Value(); err != nil || n != 3 {
+		t.Fatalf("hook counted %d, %v, want 3", n, err)
+	}
+}
+
+func TestOnSaveFailureRollsBack(t *testing.T) {
+	errHook := errors.New("hook failed")
+	s := newTestStore(t, WithOnSave(func(tx *Tx, bucket, key, val []byte) error {
+		if string(key) == "bad" {
+			return errHook
+		}
+		return nil
+	}))
+	bucket := []byte("b")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(bucket, []byte("bad"), []byte("v")); !errors.Is(err, errHook) {
+		t.Fatalf("Save = %v, want the hook error", err)
+	}
+	if _, err := s.Get(bucket, []byte("bad")); !errors.Is(err, ErrNotfound) {
+		t.Fatalf("Get after failed hook = %v, want ErrNotfound", err)
+	}
+	if _, err := s.Incr(bucket, []byte("bad")); !errors.Is(err, errHook) {
+		t.Fatalf("Incr = %v, want the hook error", err)
+	}
+	if ok, err := s.Exists(bucket, []byte("bad")); err != nil || ok {
+		t.Fatalf("Exists after failed Incr = %v, %v", ok, err)
+	}
+}
+
+func TestObserver(t *testing.T) {
+	var mu sync.Mutex
+	var ops []string
+	s := newTestStore(t, WithObserver(func(op string, d time.Duration, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		ops = append(ops, fmt.Sprintf("%s:%v", op, err != nil))
+	}))
+	bucket := []byte("b")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	all := func(key, val []byte) bool { return true }
+
+	_ = s.Save(bucket, []byte("k"), []byte("v"))
+	_, _ = s.Get(bucket, []byte("missing"))
+	_, _ = s.Decr(bucket, []byte("n"))
+	_ = s.Delete(bucket, []byte("k"))
+	_ = s.Scan(bucket, all)
+	_ = s.FindPrefix(bucket, []byte("k"), all)
+	_ = s.FindPrefixLimit(bucket, []byte("k"), 1, all)
+	_ = s.FindBetween(bucket, []byte("a"), []byte("z"), all)
+	_ = s.FindBetweenEx(bucket, []byte("a"), []byte("z"), false, false, all)
+	_ = s.FindSuffix(bucket, []byte("k"), all)
+	_, _, _ = s.LastWithPrefix(bucket, []byte("k"))
+	_, _, _, _ = s.Page(bucket, nil, 10)
+	_ = s.Scan([]byte("missing"), all)
+
+	want := "[Save:false Get:true Incr:false Delete:false Scan:false FindPrefix:false FindPrefix:false " +
+		"FindBetween:false FindBetween:false FindSuffix:false LastWithPrefix:true Page:false Scan:true]"
+	mu.Lock()
+	defer mu.Unlock()
+	if got := fmt.Sprint(ops); got != want {
+		t.Fatalf("observed\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMaxValueSize(t *testing.T) {
+	s := newTestStore(t, WithMaxValueSize(8))
+	bucket := []byte("b")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(bucket, []byte("fits"), []byte("12345678")); err != nil {
+		t.Fatalf("Save at the limit = %v", err)
+	}
+	if err := s.Save(bucket, []byte("big"), []byte("123456789")); !errors.Is(err, ErrValueTooLarge) {
+		t.Fatalf("Save over the limit = %v, want ErrValueTooLarge", err)
+	}
+	if _, err := s.Get(bucket, []byte("big")); !errors.Is(err, ErrNotfound) {
+		t.Fatalf("Get of the rejected val = %v, want ErrNotfound", err)
+	}
+}
+
+// benchmarkGrowth writes 256MB, or 16MB with -short, into a fresh store opened with opts
+// in each iteration, enough for the default store to remap many times over
+func benchmarkGrowth(b *testing.B, opts ...Option) {
+	batches := 256
+	if testing.Short() {
+		batches = 16
+	}
+	val := make([]byte, 4096)
+	pairs := make([]KV, 256)
+	b.SetBytes(int64(batches * len(pairs) * len(val)))
+	for i := 0; i < b.N; i++ {
+		s, cleanup, err := NewTempStore(opts...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err = s.CreateBucketIfNotExist([]byte("b")); err != nil {
+			b.Fatal(err)
+		}
+		for batch := 0; batch < batches; batch++ {
+			for j := range pairs {
+				pairs[j] = KV{Key: Uint64Key(uint64(batch*len(pairs) + j)), Val: val}
+			}
+			if err = s.SaveBatch([]byte("b"), pairs); err != nil {
+				b.Fatal(err)
+			}
+		}
+		cleanup()
+	}
+}
+
+func BenchmarkGrowthDefault(b *testing.B) {
+	benchmarkGrowth(b)
+}
+
+func BenchmarkGrowthPresized(b *testing.B) {
+	benchmarkGrowth(b, WithInitialMmapSize(512<<20), WithAllocSize(64<<20))
+}
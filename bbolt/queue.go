@@ -0,0 +1,64 @@
+package db
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	ErrQueueFull = errors.New("queue ids are exhausted")
+)
+
+// Queue is a durable FIFO over one bucket. Items are keyed by fixed 8-byte
+// big-endian ids from the bucket sequence, so key order is push order.
+type Queue struct {
+	s      *Store
+	bucket []byte
+}
+
+// Queue returns the queue over bucket
+func (s *Store) Queue(bucket []byte) *Queue {
+	return &Queue{s: s, bucket: bucket}
+}
+
+// Push val to the back of the queue and returns its id
+func (q *Queue) Push(val []byte) (id uint64, err error) {
+	err = q.s.Update(func(tx *Tx) error {
+		b, err := tx.bucket(q.bucket)
+		if err != nil {
+			return err
+		}
+		// the sequence would wrap to zero and break the ordering
+		if b.Sequence() == math.MaxUint64 {
+			return ErrQueueFull
+		}
+		if id, err = b.NextSequence(); err != nil {
+			return err
+		}
+		return tx.put(q.bucket, b, Uint64Key(id), val, 0)
+	})
+	return
+}
+
+// Pop removes and returns the val at the front of the queue, ErrNotfound when it is empty
+func (q *Queue) Pop() (val []byte, err error) {
+	err = q.s.Update(func(tx *Tx) error {
+		b, err := tx.bucket(q.bucket)
+		if err != nil {
+			return err
+		}
+		c := b.Cursor()
+		k, v := c.First()
+		for k != nil && v == nil {
+			k, v = c.Next()
+		}
+		if k == nil {
+			return ErrNotfound
+		}
+		if val, err = tx.Get(q.bucket, k); err != nil {
+			return err
+		}
+		return tx.Delete(q.bucket, clone(k))
+	})
+	return
+}
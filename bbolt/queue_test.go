@@ -0,0 +1,53 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestQueueFIFO(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("q")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	q := s.Queue(bucket)
+	const items = 300
+	for i := 0; i < items; i++ {
+		if _, err := q.Push([]byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < items; i++ {
+		if val, err := q.Pop(); err != nil || string(val) != fmt.Sprint(i) {
+			t.Fatalf("Pop %d = %q, %v", i, val, err)
+		}
+	}
+	if _, err := q.Pop(); !errors.Is(err, ErrNotfound) {
+		t.Fatalf("Pop of an empty queue = %v, want ErrNotfound", err)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("q")
+	err := s.Update(func(tx *Tx) error {
+		b, err := tx.tx.CreateBucket(bucket)
+		if err != nil {
+			return err
+		}
+		return b.SetSequence(math.MaxUint64 - 1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := s.Queue(bucket)
+	if id, err := q.Push([]byte("last")); err != nil || id != math.MaxUint64 {
+		t.Fatalf("Push = %d, %v, want the last id", id, err)
+	}
+	if _, err := q.Push([]byte("more")); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Push past the last id = %v, want ErrQueueFull", err)
+	}
+}
@@ -0,0 +1,21 @@
+package db
+
+// ReduceBetween decodes every val with key in [start, end] of bucket and folds it into acc
+// with step in key order, iteration stops at the first decode error which is returned
+func ReduceBetween[T, A any](s *Store, bucket, start, end []byte, decode func([]byte) (T, error), init A, step func(A, []byte, T) A) (acc A, err error) {
+	acc = init
+	var decodeErr error
+	err = s.FindBetween(bucket, start, end, func(key, val []byte) bool {
+		v, err := decode(val)
+		if err != nil {
+			decodeErr = err
+			return false
+		}
+		acc = step(acc, key, v)
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	return acc, err
+}
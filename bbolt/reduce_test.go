@@ -0,0 +1,30 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func decodeInt(val []byte) (n int, err error) {
+	err = json.Unmarshal(val, &n)
+	return
+}
+
+func TestReduceBetween(t *testing.T) {
+	s := newTestStore(t)
+	for i := 1; i <= 10; i++ {
+		mustSave(t, s, "b", fmt.Sprintf("k%02d", i), fmt.Sprint(i))
+	}
+	sum, err := ReduceBetween(s, []byte("b"), []byte("k03"), []byte("k05"), decodeInt, 0,
+		func(acc int, key []byte, n int) int { return acc + n })
+	if err != nil || sum != 3+4+5 {
+		t.Fatalf("ReduceBetween = %d, %v, want 12", sum, err)
+	}
+
+	mustSave(t, s, "b", "k04", "not a number")
+	if _, err := ReduceBetween(s, []byte("b"), []byte("k01"), []byte("k10"), decodeInt, 0,
+		func(acc int, key []byte, n int) int { return acc + n }); err == nil {
+		t.Fatal("ReduceBetween over a bad val succeeded")
+	}
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"reflect"
+	"sync"
+)
+
+// registry holds the stores open in this process by absolute path. bbolt locks the file
+// exclusively, so opening it a second time would block forever instead of sharing.
+// opening holds a channel per path being opened, closed once that open returns, so
+// bbolt.Open runs without holding mu.
+var registry = struct {
+	mu      sync.Mutex
+	stores  map[string]*Store
+	opening map[string]chan struct{}
+}{stores: make(map[string]*Store), opening: make(map[string]chan struct{})}
+
+// release drops one reference to s and reports whether others remain
+func (s *Store) release() bool {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if s.refs > 1 {
+		s.refs--
+		return true
+	}
+	if registry.stores[s.key] == s {
+		delete(registry.stores, s.key)
+	}
+	s.refs = 0
+	return false
+}
+
+// shareable reports whether a store configured as o may share the open store s.
+// Options that change how vals are read or written must match, and callbacks
+// cannot be added to a store that is already open.
+func (s *Store) shareable(o *Store) bool {
+	if s.readOnly() != o.readOnly() || s.checksum != o.checksum || s.maxValSize != o.maxValSize {
+		return false
+	}
+	if (s.aead == nil) != (o.aead == nil) || s.keyID != o.keyID {
+		return false
+	}
+	if !sameCompressor(s.compressor, o.compressor) {
+		return false
+	}
+	_, nop := o.logger.(nopLogger)
+	return nop && o.observer == nil && len(o.onSave) == 0
+}
+
+func (s *Store) readOnly() bool {
+	return s.opts != nil && s.opts.ReadOnly
+}
+
+func sameCompressor(a, b Compressor) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	return ta == tb && ta.Comparable() && a == b
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestNewStoreShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.db")
+	a, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Fatal("second open returned a different store")
+	}
+	mustSave(t, a, "b", "k", "v")
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if val, err := b.Get([]byte("b"), []byte("k")); err != nil || string(val) != "v" {
+		t.Fatalf("Get after one Close = %q, %v", val, err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Get([]byte("b"), []byte("k")); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Get after last Close = %v, want ErrClosed", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close after the last Close = %v, want nil", err)
+	}
+}
+
+func TestNewStoreOptionMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mismatch.db")
+	s, err := NewStore(path, WithChecksum(), WithEncryption(make([]byte, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	key := make([]byte, 32)
+	key[0] = 1
+	for name, opts := range map[string][]Option{
+		"plain":       nil,
+		"compression": {WithChecksum(), WithEncryption(make([]byte, 32)), WithCompression(Gzip)},
+		"other key":   {WithChecksum(), WithEncryption(key)},
+		"no checksum": {WithEncryption(make([]byte, 32))},
+		"max size":    {WithChecksum(), WithEncryption(make([]byte, 32)), WithMaxValueSize(10)},
+		"read-only":   {WithChecksum(), WithEncryption(make([]byte, 32)), WithBoltOptions(&bolt.Options{ReadOnly: true})},
+		"observer": {WithChecksum(), WithEncryption(make([]byte, 32)),
+			WithObserver(func(string, time.Duration, error) {})},
+	} {
+		if _, err := NewStore(path, opts...); !errors.Is(err, ErrAlreadyOpen) {
+			t.Errorf("%s: NewStore = %v, want ErrAlreadyOpen", name, err)
+		}
+	}
+
+	same, err := NewStore(path, WithChecksum(), WithEncryption(make([]byte, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same != s {
+		t.Fatal("matching options returned a different store")
+	}
+	_ = same.Close()
+}
+
+func TestNewStoreUnrelatedPathNotBlocked(t *testing.T) {
+	dir := t.TempDir()
+	locked := filepath.Join(dir, "locked.db")
+	held, err := bolt.Open(locked, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened := make(chan error, 1)
+	go func() {
+		s, err := NewStore(locked)
+		if err == nil {
+			err = s.Close()
+		}
+		opened <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		s, err := NewStore(filepath.Join(dir, "other.db"))
+		if err == nil {
+			err = s.Close()
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("open of an unrelated path blocked behind a locked one")
+	}
+
+	_ = held.Close()
+	if err := <-opened; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,39 @@
+package db
+
+// Reader reads from a consistent snapshot of the store, see Snapshot
+type Reader struct {
+	tx *Tx
+}
+
+// Snapshot runs fn with a Reader over one read-only transaction, so every read in fn
+// sees the store as of the same instant while writers carry on. A long fn keeps old
+// pages from being reused and grows the file, so keep it short. Writing to the store from
+// within fn can deadlock when the write has to grow the mmap, see WithInitialMmapSize.
+func (s *Store) Snapshot(fn func(r *Reader) error) error {
+	return s.View(func(tx *Tx) error {
+		return fn(&Reader{tx: tx})
+	})
+}
+
+// Get val by key from bucket
+func (r *Reader) Get(bucket, key []byte) ([]byte, error) {
+	return r.tx.Get(bucket, key)
+}
+
+// Scan every key in bucket like Store.Scan
+func (r *Reader) Scan(bucket []byte, next func(key, val []byte) bool) error {
+	b, err := r.tx.bucket(bucket)
+	if err != nil {
+		return err
+	}
+	return ignoreStop(r.tx.scan(b, next))
+}
+
+// FindPrefix find val by prefix from bucket like Store.FindPrefix
+func (r *Reader) FindPrefix(bucket, prefix []byte, next func(key, val []byte) bool) error {
+	b, err := r.tx.bucket(bucket)
+	if err != nil {
+		return err
+	}
+	return ignoreStop(r.tx.findPrefix(b, prefix, 0, next))
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestSnapshotIsolation(t *testing.T) {
+	// a mapping big enough that the concurrent write never has to remap
+	s := newTestStore(t, WithInitialMmapSize(8<<20))
+	bucket := []byte("b")
+	mustSave(t, s, "b", "k", "before")
+
+	written := make(chan error, 1)
+	err := s.Snapshot(func(r *Reader) error {
+		first, err := r.Get(bucket, []byte("k"))
+		if err != nil {
+			return err
+		}
+		go func() {
+			written <- s.Save(bucket, []byte("k"), []byte("after"))
+		}()
+		if err := <-written; err != nil {
+			return err
+		}
+		second, err := r.Get(bucket, []byte("k"))
+		if err != nil {
+			return err
+		}
+		if string(first) != "before" || string(second) != "before" {
+			t.Errorf("snapshot read %q then %q, want before twice", first, second)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, err := s.Get(bucket, []byte("k")); err != nil || string(val) != "after" {
+		t.Fatalf("Get after the snapshot = %q, %v, want after", val, err)
+	}
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"os"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// StoreSummary sizes the whole store
+type StoreSummary struct {
+	FileSize    int64
+	BucketCount int
+	TotalKeys   int
+}
+
+// FreeReport tells how much of the database file is free pages that Compact would return
+type FreeReport struct {
+	FreePageN    int // pages free for reuse
+	PendingPageN int // pages freed but still held by open read transactions
+	FreeAlloc    int // bytes in free and pending pages
+	FileSize     int64
+	// FragmentationRatio is FreeAlloc over FileSize, 0 for an empty file
+	FragmentationRatio float64
+}
+
+// Stats returns the database statistics from bbolt
+func (s *Store) Stats() bolt.Stats {
+	s.swap.RLock()
+	defer s.swap.RUnlock()
+	return s.db.Stats()
+}
+
+// BucketStats returns the statistics of bucket
+func (s *Store) BucketStats(bucket []byte) (stats bolt.BucketStats, err error) {
+	err = s.View(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		stats = b.Stats()
+		return nil
+	})
+	return
+}
+
+// Summary returns the size of the database file and the key count over all top-level buckets,
+// leaving out the history of a VersionedStore
+func (s *Store) Summary() (sum StoreSummary, err error) {
+	info, err := os.Stat(s.key)
+	if err != nil {
+		return sum, err
+	}
+	sum.FileSize = info.Size()
+
+	err = s.View(func(tx *Tx) error {
+		return tx.tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if isHistory(name) {
+				return nil
+			}
+			sum.BucketCount++
+			sum.TotalKeys += b.Stats().KeyN
+			return nil
+		})
+	})
+	return
+}
+
+// FreePageReport returns the free space of the database file, bbolt refreshes the freelist
+// counts when a write transaction ends so they lag any write still in progress
+func (s *Store) FreePageReport() (r FreeReport, err error) {
+	if s.closed() {
+		return r, ErrClosed
+	}
+	info, err := os.Stat(s.key)
+	if err != nil {
+		return r, err
+	}
+	stats := s.Stats()
+	r = FreeReport{
+		FreePageN:    stats.FreePageN,
+		PendingPageN: stats.PendingPageN,
+		FreeAlloc:    stats.FreeAlloc,
+		FileSize:     info.Size(),
+	}
+	if r.FileSize > 0 {
+		r.FragmentationRatio = float64(r.FreeAlloc) / float64(r.FileSize)
+	}
+	return r, nil
+}
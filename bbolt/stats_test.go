@@ -0,0 +1,89 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestSummaryRelativePath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStore("rel.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	mustSave(t, s, "a", "k1", "v")
+	mustSave(t, s, "a", "k2", "v")
+	mustSave(t, s, "b", "k1", "v")
+
+	// the store keeps working once the relative path points elsewhere
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := s.Summary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.BucketCount != 2 || sum.TotalKeys != 3 || sum.FileSize == 0 {
+		t.Fatalf("Summary = %+v, want 2 buckets and 3 keys", sum)
+	}
+	if _, err := s.FreePageReport(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	s := newTestStore(t)
+	for i := 0; i < 100; i++ {
+		mustSave(t, s, "b", fmt.Sprintf("k%03d", i), "v")
+	}
+	if stats := s.Stats(); stats.TxStats.Write == 0 || stats.TxStats.PageAlloc == 0 {
+		t.Fatalf("Stats after writes = %+v", stats)
+	}
+	bs, err := s.BucketStats([]byte("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bs.KeyN != 100 || bs.Depth == 0 {
+		t.Fatalf("BucketStats = %+v, want 100 keys", bs)
+	}
+	if _, err := s.BucketStats([]byte("missing")); !errors.Is(err, ErrBucketNotFound) {
+		t.Fatalf("BucketStats of a missing bucket = %v, want ErrBucketNotFound", err)
+	}
+}
+
+func TestFreePageReport(t *testing.T) {
+	s := newTestStore(t)
+	val := make([]byte, 1024)
+	for i := 0; i < 500; i++ {
+		mustSave(t, s, "b", fmt.Sprintf("k%03d", i), string(val))
+	}
+	before, err := s.FreePageReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 500; i++ {
+		if err := s.Delete([]byte("b"), []byte(fmt.Sprintf("k%03d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	after, err := s.FreePageReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.FreePageN+after.PendingPageN <= before.FreePageN+before.PendingPageN || after.FreeAlloc <= before.FreeAlloc {
+		t.Fatalf("free pages did not grow after deletes: before %+v, after %+v", before, after)
+	}
+	if after.FragmentationRatio <= 0 || after.FragmentationRatio > 1 {
+		t.Fatalf("FragmentationRatio = %v, want in (0, 1]", after.FragmentationRatio)
+	}
+}
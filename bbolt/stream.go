@@ -0,0 +1,197 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// importBatchSize bounds how many pairs one import transaction holds
+const importBatchSize = 4096
+
+// frameChunkSize is how much readFrame allocates up front for a frame
+const frameChunkSize = 64 << 10
+
+var (
+	ErrInvalidFrame = errors.New("invalid key/value frame")
+)
+
+// Import reads pairs framed as uvarint key length, key, uvarint val length, val
+// until r is drained and saves them to bucket, creating it if needed.
+// Pairs are committed in batches, so on error the ones before the failing batch are kept.
+func (s *Store) Import(bucket []byte, r io.Reader) (count int, err error) {
+	br := bufio.NewReader(r)
+	return s.importPairs(bucket, func() (p KV, err error) {
+		if p.Key, err = readFrame(br, bolt.MaxKeySize); err != nil {
+			return
+		}
+		if p.Val, err = readFrame(br, bolt.MaxValueSize); err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return
+	})
+}
+
+// Export writes every pair of bucket to w in the framing read by Import
+func (s *Store) Export(bucket []byte, w io.Writer) (count int, err error) {
+	return s.exportPairs(bucket, w, func(w *bufio.Writer, p KV) error {
+		if err := writeFrame(w, p.Key); err != nil {
+			return err
+		}
+		return writeFrame(w, p.Val)
+	})
+}
+
+// ImportJSONL reads one JSON encoded KV per line, with base64 key and value, like Import
+func (s *Store) ImportJSONL(bucket []byte, r io.Reader) (count int, err error) {
+	dec := json.NewDecoder(r)
+	return s.importPairs(bucket, func() (p KV, err error) {
+		err = dec.Decode(&p)
+		return
+	})
+}
+
+// ExportJSONL writes every pair of bucket to w as one JSON encoded KV per line
+func (s *Store) ExportJSONL(bucket []byte, w io.Writer) (count int, err error) {
+	return s.exportPairs(bucket, w, func(w *bufio.Writer, p KV) error {
+		return json.NewEncoder(w).Encode(p)
+	})
+}
+
+// importPairs saves the pairs from read until it returns io.EOF, in batches of importBatchSize
+func (s *Store) importPairs(bucket []byte, read func() (KV, error)) (count int, err error) {
+	if err = s.CreateBucketIfNotExist(bucket); err != nil {
+		return
+	}
+
+	pairs := make([]KV, 0, importBatchSize)
+	for {
+		var p KV
+		if p, err = read(); err == io.EOF {
+			break
+		} else if err != nil {
+			return
+		}
+
+		if pairs = append(pairs, p); len(pairs) == importBatchSize {
+			if err = s.SaveBatch(bucket, pairs); err != nil {
+				return
+			}
+			count += len(pairs)
+			pairs = pairs[:0]
+		}
+	}
+
+	if err = s.SaveBatch(bucket, pairs); err != nil {
+		return
+	}
+	count += len(pairs)
+	return
+}
+
+// exportPairs calls write for every pair of bucket and flushes w at the end
+func (s *Store) exportPairs(bucket []byte, w io.Writer, write func(*bufio.Writer, KV) error) (count int, err error) {
+	bw := bufio.NewWriter(w)
+	var werr error
+	err = s.Scan(bucket, func(key, val []byte) bool {
+		if werr = write(bw, KV{Key: key, Val: val}); werr != nil {
+			return false
+		}
+		count++
+		return true
+	})
+	if err == nil {
+		err = werr
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	return
+}
+
+// readFrame reads one length-prefixed frame, io.EOF means r ended cleanly before it
+func readFrame(r *bufio.Reader, max int) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(max) {
+		return nil, ErrInvalidFrame
+	}
+	// grow with what actually arrives, a corrupt length must not allocate up to max
+	var buf bytes.Buffer
+	buf.Grow(int(min(n, frameChunkSize)))
+	if _, err = io.CopyN(&buf, r, int64(n)); err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), err
+}
+
+func writeFrame(w *bufio.Writer, data []byte) error {
+	var size [binary.MaxVarintLen64]byte
+	if _, err := w.Write(size[:binary.PutUvarint(size[:], uint64(len(data)))]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// GetReader returns the val of key in bucket as a reader over the database pages instead of a copy.
+// It holds a read transaction open until Close, which keeps writers from reusing pages and can
+// block them once the file has to grow, so Close it as soon as the val is consumed.
+func (s *Store) GetReader(bucket, key []byte) (io.ReadCloser, error) {
+	s.swap.RLock()
+	btx, err := s.db.Begin(false)
+	if err != nil {
+		s.swap.RUnlock()
+		return nil, txError(err)
+	}
+	val, err := s.getRaw(s.newTx(btx), bucket, key)
+	if err != nil {
+		_ = btx.Rollback()
+		s.swap.RUnlock()
+		return nil, err
+	}
+	return &valueReader{Reader: bytes.NewReader(val), s: s, tx: btx}, nil
+}
+
+// getRaw is Tx.Get without the copy, the val is only valid while tx is open
+func (s *Store) getRaw(tx *Tx, bucket, key []byte) ([]byte, error) {
+	b, err := tx.bucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	raw := b.Get(key)
+	if raw == nil {
+		return nil, ErrNotfound
+	}
+	val, expire, err := s.decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	if expired(expire, tx.now) {
+		return nil, ErrNotfound
+	}
+	return val, nil
+}
+
+type valueReader struct {
+	*bytes.Reader
+	s    *Store
+	tx   *bolt.Tx
+	once sync.Once
+}
+
+func (r *valueReader) Close() (err error) {
+	r.once.Do(func() {
+		err = r.tx.Rollback()
+		r.s.swap.RUnlock()
+	})
+	return
+}
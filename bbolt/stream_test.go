@@ -0,0 +1,135 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestExportImport(t *testing.T) {
+	s := newTestStore(t)
+	for i := 0; i < 10; i++ {
+		mustSave(t, s, "src", fmt.Sprintf("k%d", i), fmt.Sprintf("v%d", i))
+	}
+	mustSave(t, s, "src", "empty", "")
+
+	var buf bytes.Buffer
+	if n, err := s.Export([]byte("src"), &buf); err != nil || n != 11 {
+		t.Fatalf("Export = %d, %v", n, err)
+	}
+	if n, err := s.Import([]byte("dst"), &buf); err != nil || n != 11 {
+		t.Fatalf("Import = %d, %v", n, err)
+	}
+	for i := 0; i < 10; i++ {
+		if val, err := s.Get([]byte("dst"), []byte(fmt.Sprintf("k%d", i))); err != nil || string(val) != fmt.Sprintf("v%d", i) {
+			t.Fatalf("Get(k%d) = %q, %v", i, val, err)
+		}
+	}
+	if val, err := s.Get([]byte("dst"), []byte("empty")); err != nil || len(val) != 0 {
+		t.Fatalf("Get(empty) = %q, %v", val, err)
+	}
+}
+
+func TestReadFrameTruncated(t *testing.T) {
+	var size [binary.MaxVarintLen64]byte
+	data := append(size[:binary.PutUvarint(size[:], bolt.MaxValueSize-1)], "abc"...)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := readFrame(bufio.NewReader(bytes.NewReader(data)), bolt.MaxValueSize)
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("readFrame = %v, want io.ErrUnexpectedEOF", err)
+	}
+	if grown := after.TotalAlloc - before.TotalAlloc; grown > 1<<20 {
+		t.Fatalf("readFrame allocated %d bytes for a 3 byte frame", grown)
+	}
+}
+
+func TestImportTruncated(t *testing.T) {
+	s := newTestStore(t)
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	_ = writeFrame(w, []byte("k"))
+	_ = w.Flush()
+	if _, err := s.Import([]byte("b"), &buf); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Import = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestJSONLRoundTrip(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	for i := 0; i < 20; i++ {
+		mustSave(t, s, "b", fmt.Sprintf("k%02d", i), fmt.Sprintf("v\n%02d\x00", i))
+	}
+	var before bytes.Buffer
+	if _, err := s.Export(bucket, &before); err != nil {
+		t.Fatal(err)
+	}
+
+	var jsonl bytes.Buffer
+	if n, err := s.ExportJSONL(bucket, &jsonl); err != nil || n != 20 {
+		t.Fatalf("ExportJSONL = %d, %v", n, err)
+	}
+	if lines := bytes.Count(jsonl.Bytes(), []byte("\n")); lines != 20 {
+		t.Fatalf("ExportJSONL wrote %d lines, want 20", lines)
+	}
+	if _, err := s.Truncate(bucket); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.ImportJSONL(bucket, &jsonl); err != nil || n != 20 {
+		t.Fatalf("ImportJSONL = %d, %v", n, err)
+	}
+
+	var after bytes.Buffer
+	if _, err := s.Export(bucket, &after); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before.Bytes(), after.Bytes()) {
+		t.Fatal("bucket differs after the JSONL round trip")
+	}
+}
+
+func TestGetReader(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	large := bytes.Repeat([]byte("0123456789"), 1<<17)
+	if err := s.SaveAutoBucket(bucket, []byte("big"), large); err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.GetReader(bucket, []byte("big"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(got, large) {
+		t.Fatalf("read %d bytes, %v, want %d", len(got), err, len(large))
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("second Close = %v", err)
+	}
+	if open := s.Stats().OpenTxN; open != 0 {
+		t.Fatalf("%d read transactions open after Close", open)
+	}
+	// with the reader closed nothing holds off compaction
+	if err := s.CompactInPlace(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetReader(bucket, []byte("missing")); !errors.Is(err, ErrNotfound) {
+		t.Fatalf("GetReader of a missing key = %v, want ErrNotfound", err)
+	}
+	if open := s.Stats().OpenTxN; open != 0 {
+		t.Fatalf("%d read transactions open after a failed GetReader", open)
+	}
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestConcurrentStress runs the documented concurrent uses of a Store together,
+// it is meant for go test -race
+func TestConcurrentStress(t *testing.T) {
+	var observed atomic.Int64
+	s := newTestStore(t, WithObserver(func(string, time.Duration, error) { observed.Add(1) }))
+	bucket := []byte("b")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	stop := s.StartExpiryReaper(time.Millisecond)
+	defer stop()
+	cache := NewCachedStore(s, 16)
+
+	events, unwatch := s.Watch(bucket)
+	drained := make(chan int)
+	go func() {
+		n := 0
+		for range events {
+			n++
+		}
+		drained <- n
+	}()
+
+	const workers, rounds = 8, 200
+	var wg sync.WaitGroup
+	var incrs uint64
+	for w := 0; w < workers; w++ {
+		for i := 0; i < rounds; i++ {
+			if (w+i)%6 == 2 {
+				incrs++
+			}
+		}
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				key := []byte(fmt.Sprintf("k%d", i%20))
+				var err error
+				switch (w + i) % 6 {
+				case 0:
+					err = s.Save(bucket, key, []byte("v"))
+				case 1:
+					_, err = s.Get(bucket, key)
+				case 2:
+					_, err = s.Incr(bucket, []byte("counter"))
+				case 3:
+					err = s.Delete(bucket, key)
+				case 4:
+					err = s.Scan(bucket, func(key, val []byte) bool { return true })
+				case 5:
+					if err = cache.Save(bucket, key, []byte("c")); err == nil {
+						_, err = cache.Get(bucket, key)
+					}
+					if err == nil {
+						err = s.SaveWithTTL(bucket, append(key, 't'), []byte("v"), time.Millisecond)
+					}
+				}
+				// keys deleted or expired by other workers read as missing
+				if err != nil && !errors.Is(err, ErrNotfound) {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	unwatch()
+	if n := <-drained; n == 0 {
+		t.Error("watcher saw no events")
+	}
+	if observed.Load() == 0 {
+		t.Error("observer saw no calls")
+	}
+
+	n, err := s.Counter(bucket, []byte("counter")).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != incrs {
+		t.Fatalf("counter = %d, want %d", n, incrs)
+	}
+}
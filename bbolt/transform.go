@@ -0,0 +1,61 @@
+package db
+
+// Transform rewrites every val of bucket with fn, a nil result deletes the key. It commits
+// in batches of keys so a large bucket does not build one huge transaction, which also means
+// an error leaves the batches before it applied. TTLs are kept and expired keys are skipped.
+// Returns how many keys were rewritten or deleted.
+func (s *Store) Transform(bucket []byte, fn func(key, val []byte) ([]byte, error)) (n int, err error) {
+	var after []byte
+	for done := false; !done; {
+		err = s.Update(func(tx *Tx) error {
+			b, err := tx.bucket(bucket)
+			if err != nil {
+				return err
+			}
+
+			// collect first, writing under the cursor would move it
+			type rewrite struct {
+				key, val []byte
+				expire   int64
+			}
+			var batch []rewrite
+			c := b.Cursor()
+			k, v := seekAfter(c, after)
+			for ; k != nil && len(batch) < importBatchSize; k, v = c.Next() {
+				after = clone(k)
+				if v == nil {
+					continue
+				}
+				val, expire, err := s.decode(v)
+				if err != nil {
+					return err
+				}
+				if expired(expire, tx.now) {
+					continue
+				}
+				if val, err = fn(clone(k), clone(val)); err != nil {
+					return err
+				}
+				batch = append(batch, rewrite{key: after, val: val, expire: expire})
+			}
+			done = k == nil
+
+			for _, r := range batch {
+				if r.val == nil {
+					err = tx.Delete(bucket, r.key)
+				} else {
+					err = tx.put(bucket, b, r.key, r.val, r.expire)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			n += len(batch)
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	total := 2*importBatchSize + 10
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	err := s.Update(func(tx *Tx) error {
+		for i := 0; i < total; i++ {
+			if err := tx.Save(bucket, []byte(fmt.Sprintf("k%05d", i)), []byte(strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// double the even numbers and drop the odd ones
+	n, err := s.Transform(bucket, func(key, val []byte) ([]byte, error) {
+		i, err := strconv.Atoi(string(val))
+		if err != nil {
+			return nil, err
+		}
+		if i%2 == 1 {
+			return nil, nil
+		}
+		return []byte(strconv.Itoa(2 * i)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != total {
+		t.Fatalf("Transform = %d, want %d", n, total)
+	}
+
+	for _, i := range []int{0, 2, importBatchSize, importBatchSize + 2, total - 2} {
+		val, err := s.Get(bucket, []byte(fmt.Sprintf("k%05d", i)))
+		if err != nil || string(val) != strconv.Itoa(2*i) {
+			t.Fatalf("Get(k%05d) = %q, %v, want %d", i, val, err, 2*i)
+		}
+	}
+	for _, i := range []int{1, importBatchSize - 1, importBatchSize + 1, total - 1} {
+		if _, err := s.Get(bucket, []byte(fmt.Sprintf("k%05d", i))); !errors.Is(err, ErrNotfound) {
+			t.Fatalf("Get(k%05d) = %v, want ErrNotfound", i, err)
+		}
+	}
+	if c, err := s.Count(bucket); err != nil || c != total/2 {
+		t.Fatalf("Count = %d, %v, want %d", c, err, total/2)
+	}
+}
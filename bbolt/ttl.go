@@ -0,0 +1,103 @@
+package db
+
+import (
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// SaveWithTTL save key and val to bucket, the key reads as missing once ttl has passed
+func (s *Store) SaveWithTTL(bucket, key, val []byte, ttl time.Duration) error {
+	return s.Update(func(tx *Tx) error {
+		b, err := tx.bucket(bucket)
+		if err != nil {
+			return err
+		}
+		return tx.put(bucket, b, key, val, time.Now().Add(ttl).UnixNano())
+	})
+}
+
+// StartExpiryReaper deletes expired keys from all buckets every interval until stop is called,
+// failed passes are reported to the store Logger. Close stops it as well.
+// An interval that is not positive starts nothing, logs a warning and returns a no-op stop.
+func (s *Store) StartExpiryReaper(interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		s.logger.Printf("kvass: expiry reaper not started, interval %v is not positive", interval)
+		return func() {}
+	}
+	done := make(chan struct{})
+	reaper := func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-s.done:
+				return
+			case <-ticker.C:
+				if _, err := s.reap(); err != nil {
+					s.logger.Printf("kvass: expiry reaper: %v", err)
+				}
+			}
+		}
+	}
+	if !s.goBackground(reaper) {
+		return func() {}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// reap deletes expired keys and returns how many were removed
+func (s *Store) reap() (n int, err error) {
+	err = s.Update(func(tx *Tx) error {
+		var names [][]byte
+		_ = tx.tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			names = append(names, name)
+			return nil
+		})
+
+		for _, name := range names {
+			deleted, err := s.reapBucket(tx.tx.Bucket(name), tx.now)
+			n += deleted
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return
+}
+
+func (s *Store) reapBucket(b *bolt.Bucket, now int64) (n int, err error) {
+	// collect first, changing the bucket under a moving cursor can skip keys
+	var keys, subs [][]byte
+	_ = b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			subs = append(subs, k)
+		} else if _, expire, _, err := frame(v); err == nil && expired(expire, now) {
+			keys = append(keys, k)
+		}
+		return nil
+	})
+
+	for _, k := range keys {
+		if err = b.Delete(k); err != nil {
+			return
+		}
+		n++
+	}
+	for _, k := range subs {
+		deleted, err := s.reapBucket(b.Bucket(k), now)
+		n += deleted
+		if err != nil {
+			return n, err
+		}
+	}
+	return
+}
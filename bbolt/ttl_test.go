@@ -0,0 +1,81 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTTLMixedWithPlain(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	magic := valueMagic + "plain"
+	mustSave(t, s, "b", "plain", "v")
+	mustSave(t, s, "b", "magic", magic)
+	if err := s.SaveWithTTL(bucket, []byte("short"), []byte("gone"), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveWithTTL(bucket, []byte("long"), []byte("kept"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	for key, want := range map[string]string{"plain": "v", "magic": magic, "long": "kept"} {
+		if val, err := s.Get(bucket, []byte(key)); err != nil || string(val) != want {
+			t.Errorf("Get(%s) = %q, %v, want %q", key, val, err, want)
+		}
+	}
+	if _, err := s.Get(bucket, []byte("short")); !errors.Is(err, ErrNotfound) {
+		t.Errorf("Get(short) = %v, want ErrNotfound", err)
+	}
+
+	if n, err := s.reap(); err != nil || n != 1 {
+		t.Fatalf("reap = %d, %v, want 1", n, err)
+	}
+	if n, err := s.Count(bucket); err != nil || n != 3 {
+		t.Fatalf("Count after reap = %d, %v, want 3", n, err)
+	}
+}
+
+func TestStartExpiryReaper(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveWithTTL(bucket, []byte("k"), []byte("v"), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	stop := s.StartExpiryReaper(5 * time.Millisecond)
+	defer stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		n, err := s.Count(bucket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("reaper did not delete the expired key")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStartExpiryReaperInvalidInterval(t *testing.T) {
+	logger := &captureLogger{}
+	s := newTestStore(t, WithLogger(logger))
+	for _, interval := range []time.Duration{0, -time.Second} {
+		stop := s.StartExpiryReaper(interval)
+		stop()
+		stop()
+	}
+	lines := logger.Lines()
+	if len(lines) != 2 || !strings.Contains(lines[0], "not positive") {
+		t.Fatalf("logged %q, want two warnings", lines)
+	}
+}
@@ -0,0 +1,229 @@
+package db
+
+import (
+	"bytes"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Tx is a transaction over the store for composite operations.
+// A Tx must not be used by other goroutines or after the func it was passed to returns,
+// vals it returns are copies but should not be held past that point for consistency.
+type Tx struct {
+	s      *Store
+	tx     *bolt.Tx
+	now    int64
+	events []Event
+}
+
+// Update runs fn in a read-write transaction, it commits if fn returns nil and rolls back otherwise
+func (s *Store) Update(fn func(tx *Tx) error) error {
+	if s.readOnly() {
+		return ErrReadOnly
+	}
+	s.swap.RLock()
+	defer s.swap.RUnlock()
+	return txError(s.db.Update(func(tx *bolt.Tx) error {
+		return fn(s.newTx(tx))
+	}))
+}
+
+// batch runs fn through bbolt's Batch which coalesces concurrent calls into one transaction,
+// fn may run more than once so it must only depend on its arguments
+func (s *Store) batch(fn func(tx *Tx) error) error {
+	if s.readOnly() {
+		return ErrReadOnly
+	}
+	s.swap.RLock()
+	defer s.swap.RUnlock()
+	return txError(s.db.Batch(func(tx *bolt.Tx) error {
+		return fn(s.newTx(tx))
+	}))
+}
+
+// View runs fn in a read-only transaction
+func (s *Store) View(fn func(tx *Tx) error) error {
+	s.swap.RLock()
+	defer s.swap.RUnlock()
+	return txError(s.db.View(func(tx *bolt.Tx) error {
+		return fn(s.newTx(tx))
+	}))
+}
+
+// txError maps bbolt refusing a transaction on a closed database to ErrClosed
+func txError(err error) error {
+	if err == bolt.ErrDatabaseNotOpen {
+		return ErrClosed
+	}
+	return err
+}
+
+func (s *Store) newTx(tx *bolt.Tx) *Tx {
+	return &Tx{s: s, tx: tx, now: time.Now().UnixNano()}
+}
+
+// Get val by key from bucket
+func (tx *Tx) Get(bucket, key []byte) ([]byte, error) {
+	b, err := tx.bucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	val, ok, err := tx.s.get(b, key, tx.now)
+	if err == nil && !ok {
+		err = ErrNotfound
+	}
+	return val, err
+}
+
+// Save key and val to bucket
+func (tx *Tx) Save(bucket, key, val []byte) error {
+	b, err := tx.bucket(bucket)
+	if err != nil {
+		return err
+	}
+	return tx.put(bucket, b, key, val, 0)
+}
+
+// Delete key from bucket
+func (tx *Tx) Delete(bucket, key []byte) error {
+	b, err := tx.bucket(bucket)
+	if err != nil {
+		return err
+	}
+	if err = b.Delete(key); err != nil {
+		return err
+	}
+	tx.emit(bucket, OpDelete, key, nil)
+	return nil
+}
+
+// Incr increase a number, see Store.Incr
+func (tx *Tx) Incr(bucket, key []byte) (uint64, error) {
+	return tx.IncrBy(bucket, key, 1)
+}
+
+// IncrBy add delta to a number, see Store.IncrBy
+func (tx *Tx) IncrBy(bucket, key []byte, delta int64) (uint64, error) {
+	b, err := tx.bucket(bucket)
+	if err != nil {
+		return 0, err
+	}
+	n, err := add(b, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	// a missing key stays missing when clamped at zero
+	if val := b.Get(key); val != nil {
+		if err = tx.saved(bucket, key, val); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// scan calls next for every live key of b in order, stopping with errStop when next returns false
+func (tx *Tx) scan(b *bolt.Bucket, next func(key, val []byte) bool) error {
+	return b.ForEach(func(k, v []byte) error {
+		val, ok, err := tx.s.value(v, tx.now)
+		if err != nil || !ok {
+			return err
+		}
+		if !next(clone(k), val) {
+			return errStop
+		}
+		return nil
+	})
+}
+
+// findPrefix calls next for up to limit live keys of b with prefix, zero means no limit
+func (tx *Tx) findPrefix(b *bolt.Bucket, prefix []byte, limit int, next func(key, val []byte) bool) error {
+	n := 0
+	c := b.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		if limit > 0 && n == limit {
+			break
+		}
+		val, ok, err := tx.s.value(v, tx.now)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		n++
+		if !next(clone(k), val) {
+			return errStop
+		}
+	}
+	return nil
+}
+
+// put frames val and writes it to key of b and runs the save hooks,
+// bucket names b for hooks and watchers and may be nil to skip them
+func (tx *Tx) put(bucket []byte, b *bolt.Bucket, key, val []byte, expire int64) error {
+	if max := tx.s.maxValSize; max > 0 && len(val) > max {
+		return ErrValueTooLarge
+	}
+	data, err := tx.s.encode(val, expire)
+	if err != nil {
+		return err
+	}
+	if err = b.Put(key, data); err != nil {
+		return err
+	}
+	return tx.saved(bucket, key, val)
+}
+
+// saved runs the save hooks and tells watchers once val was written to key of bucket,
+// a nil bucket skips both
+func (tx *Tx) saved(bucket, key, val []byte) error {
+	if bucket != nil {
+		for _, hook := range tx.s.onSave {
+			if err := hook(tx, bucket, key, val); err != nil {
+				return err
+			}
+		}
+	}
+	tx.emit(bucket, OpPut, key, val)
+	return nil
+}
+
+// deleteFrom deletes keys from start on while in reports true, nested buckets are left alone
+func (tx *Tx) deleteFrom(bucket []byte, c *bolt.Cursor, start []byte, in func(k []byte) bool) (n int, err error) {
+	for k, v := c.Seek(start); k != nil && in(k); {
+		if v == nil {
+			k, v = c.Next()
+			continue
+		}
+		k = clone(k)
+		if err = c.Delete(); err != nil {
+			return
+		}
+		n++
+		tx.emit(bucket, OpDelete, k, nil)
+		// a cursor may skip the next key after Delete, seeking again is safe
+		k, v = c.Seek(k)
+	}
+	return
+}
+
+// emit queues an Event for the watchers of bucket, they get it once the transaction commits
+func (tx *Tx) emit(bucket []byte, op Op, key, val []byte) {
+	if bucket == nil || !tx.s.watching(bucket) {
+		return
+	}
+	if tx.events == nil {
+		tx.tx.OnCommit(func() { tx.s.publish(tx.events) })
+	}
+	tx.events = append(tx.events, Event{Op: op, Bucket: clone(bucket), Key: clone(key), Val: clone(val)})
+}
+
+// bucket returns the named bucket or ErrBucketNotFound
+func (tx *Tx) bucket(name []byte) (*bolt.Bucket, error) {
+	b := tx.tx.Bucket(name)
+	if b == nil {
+		return nil, ErrBucketNotFound
+	}
+	return b, nil
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestUpdateTransfer(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("accounts")
+	mustSave(t, s, "accounts", "a", "100")
+	mustSave(t, s, "accounts", "b", "100")
+
+	balance := func(tx *Tx, key string) int {
+		val, err := tx.Get(bucket, []byte(key))
+		if err != nil {
+			t.Error(err)
+			return 0
+		}
+		n, _ := strconv.Atoi(string(val))
+		return n
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			from, to := "a", "b"
+			if w%2 == 1 {
+				from, to = to, from
+			}
+			for i := 0; i < 25; i++ {
+				err := s.Update(func(tx *Tx) error {
+					if err := tx.Save(bucket, []byte(from), []byte(strconv.Itoa(balance(tx, from)-1))); err != nil {
+						return err
+					}
+					return tx.Save(bucket, []byte(to), []byte(strconv.Itoa(balance(tx, to)+1)))
+				})
+				if err != nil {
+					t.Error(err)
+				}
+				err = s.View(func(tx *Tx) error {
+					if sum := balance(tx, "a") + balance(tx, "b"); sum != 200 {
+						t.Errorf("View saw a total of %d", sum)
+					}
+					return nil
+				})
+				if err != nil {
+					t.Error(err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func TestUpdateRollback(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	mustSave(t, s, "b", "a", "1")
+	failed := errors.New("abort")
+	err := s.Update(func(tx *Tx) error {
+		if err := tx.Save(bucket, []byte("a"), []byte("2")); err != nil {
+			return err
+		}
+		if err := tx.Save(bucket, []byte("c"), []byte("3")); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("Update = %v, want the fn error", err)
+	}
+	if val, err := s.Get(bucket, []byte("a")); err != nil || string(val) != "1" {
+		t.Fatalf("Get(a) = %q, %v, want the old val", val, err)
+	}
+	if got := keysOf(t, s, "b"); got != "[a]" {
+		t.Fatalf("keys = %s, want [a]", got)
+	}
+}
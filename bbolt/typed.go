@@ -0,0 +1,68 @@
+package db
+
+// Codec encodes values to bytes and back
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// GetInto get the val by key from bucket and decode it into dst, e.g. with json.Unmarshal
+// or the Unmarshal of a Codec. Store errors such as ErrNotfound are returned as is without decoding.
+func (s *Store) GetInto(bucket, key []byte, dst any, decode func([]byte, any) error) error {
+	val, err := s.Get(bucket, key)
+	if err != nil {
+		return err
+	}
+	return decode(val, dst)
+}
+
+// TypedStore stores values of T in one bucket encoded with a Codec
+type TypedStore[T any] struct {
+	s      *Store
+	bucket []byte
+	codec  Codec
+}
+
+// NewTypedStore returns a TypedStore over bucket of s, a nil codec uses JSON
+func NewTypedStore[T any](s *Store, bucket []byte, codec Codec) *TypedStore[T] {
+	if codec == nil {
+		codec = JSON
+	}
+	return &TypedStore[T]{s: s, bucket: bucket, codec: codec}
+}
+
+// Put encode v and save it by key
+func (t *TypedStore[T]) Put(key []byte, v T) error {
+	val, err := t.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return t.s.Save(t.bucket, key, val)
+}
+
+// Get the value by key, store errors such as ErrNotfound are returned as is
+func (t *TypedStore[T]) Get(key []byte) (v T, err error) {
+	val, err := t.s.Get(t.bucket, key)
+	if err != nil {
+		return v, err
+	}
+	err = t.codec.Unmarshal(val, &v)
+	return v, err
+}
+
+// Range calls next for every value in key order until it returns false,
+// a value that fails to decode stops the range with that error
+func (t *TypedStore[T]) Range(next func(key []byte, v T) bool) error {
+	var derr error
+	err := t.s.Scan(t.bucket, func(key, val []byte) bool {
+		var v T
+		if derr = t.codec.Unmarshal(val, &v); derr != nil {
+			return false
+		}
+		return next(key, v)
+	})
+	if err == nil {
+		err = derr
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// pointCodec encodes a *point or point as "x,y"
+type pointCodec struct{}
+
+func (pointCodec) Marshal(v any) ([]byte, error) {
+	p, ok := v.(point)
+	if !ok {
+		return nil, fmt.Errorf("pointCodec: cannot marshal %T", v)
+	}
+	return []byte(fmt.Sprintf("%d,%d", p.X, p.Y)), nil
+}
+
+func (pointCodec) Unmarshal(data []byte, v any) error {
+	p, ok := v.(*point)
+	if !ok {
+		return fmt.Errorf("pointCodec: cannot unmarshal into %T", v)
+	}
+	_, err := fmt.Sscanf(string(data), "%d,%d", &p.X, &p.Y)
+	return err
+}
+
+func TestTypedStore(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.CreateBuckets([]byte("json"), []byte("custom")); err != nil {
+		t.Fatal(err)
+	}
+	for name, ts := range map[string]*TypedStore[point]{
+		"json":   NewTypedStore[point](s, []byte("json"), nil),
+		"custom": NewTypedStore[point](s, []byte("custom"), pointCodec{}),
+	} {
+		if err := ts.Put([]byte("p"), point{X: 3, Y: 4}); err != nil {
+			t.Fatal(err)
+		}
+		if p, err := ts.Get([]byte("p")); err != nil || p != (point{X: 3, Y: 4}) {
+			t.Fatalf("%s: Get = %+v, %v", name, p, err)
+		}
+		if _, err := ts.Get([]byte("missing")); !errors.Is(err, ErrNotfound) {
+			t.Fatalf("%s: Get of a missing key = %v, want ErrNotfound", name, err)
+		}
+	}
+	if val, err := s.Get([]byte("custom"), []byte("p")); err != nil || string(val) != "3,4" {
+		t.Fatalf("custom encoding = %q, %v", val, err)
+	}
+
+	mustSave(t, s, "json", "bad", "not json")
+	_, err := NewTypedStore[point](s, []byte("json"), nil).Get([]byte("bad"))
+	var syntax *json.SyntaxError
+	if !errors.As(err, &syntax) || errors.Is(err, ErrNotfound) {
+		t.Fatalf("Get of a bad val = %v, want a decode error", err)
+	}
+}
+
+func TestGetInto(t *testing.T) {
+	s := newTestStore(t)
+	mustSave(t, s, "b", "json", `{"X":1,"Y":2}`)
+	mustSave(t, s, "b", "custom", "5,6")
+
+	var p point
+	if err := s.GetInto([]byte("b"), []byte("json"), &p, json.Unmarshal); err != nil || p != (point{X: 1, Y: 2}) {
+		t.Fatalf("GetInto with json = %+v, %v", p, err)
+	}
+	if err := s.GetInto([]byte("b"), []byte("custom"), &p, pointCodec{}.Unmarshal); err != nil || p != (point{X: 5, Y: 6}) {
+		t.Fatalf("GetInto with a codec = %+v, %v", p, err)
+	}
+
+	called := false
+	decode := func(data []byte, v any) error {
+		called = true
+		return json.Unmarshal(data, v)
+	}
+	if err := s.GetInto([]byte("b"), []byte("missing"), &p, decode); !errors.Is(err, ErrNotfound) || called {
+		t.Fatalf("GetInto of a missing key = %v, decode called %v", err, called)
+	}
+}
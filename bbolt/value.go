@@ -0,0 +1,199 @@
+package db
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Values that carry metadata are framed as
+//
+//	valueMagic | version | flags | [expire] | body | [checksum]
+//
+// anything else is a plain val as written before framing existed.
+// A plain val that happens to start with valueMagic is framed with no flags,
+// so it reads back unchanged. Counters written by Incr are always plain.
+const (
+	valueMagic   = "\xffkv"
+	valueVersion = 1
+	headerSize   = len(valueMagic) + 2
+)
+
+const (
+	// flagExpire marks an 8-byte big-endian unix-nano expiry after the header
+	flagExpire byte = 1 << iota
+	// flagCompressed marks a body written by the store Compressor
+	flagCompressed
+	// flagEncrypted marks a body sealed with the store AEAD, the nonce comes first
+	flagEncrypted
+	// flagChecksum marks a trailing big-endian CRC32 of everything before it
+	flagChecksum
+)
+
+// checksumSize is the length of the flagChecksum trailer
+const checksumSize = 4
+
+var (
+	ErrInvalidValue  = errors.New("value has an invalid header")
+	ErrNoCompression = errors.New("value is compressed but the store has no compressor")
+	ErrNoEncryption  = errors.New("value is encrypted but the store has no key")
+	ErrDecrypt       = errors.New("value failed authentication")
+
+	ErrChecksumMismatch = errors.New("value does not match its checksum")
+	ErrValueTooLarge    = errors.New("value is larger than the store allows")
+)
+
+// encode frames val for storage, expire is a unix-nano deadline or zero
+func (s *Store) encode(val []byte, expire int64) ([]byte, error) {
+	var flags byte
+	body := val
+	if s.compressor != nil {
+		// keep vals that do not shrink as they are
+		if c := s.compressor.Compress(val); len(c) < len(val) {
+			flags |= flagCompressed
+			body = c
+		}
+	}
+	if s.aead != nil {
+		flags |= flagEncrypted
+		nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(body)+s.aead.Overhead())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		body = s.aead.Seal(nonce, nonce, body, nil)
+	}
+	if expire != 0 {
+		flags |= flagExpire
+	}
+	if s.checksum {
+		flags |= flagChecksum
+	}
+	if flags == 0 && !bytes.HasPrefix(val, []byte(valueMagic)) {
+		return val, nil
+	}
+
+	size := headerSize + len(body)
+	if expire != 0 {
+		size += 8
+	}
+	if s.checksum {
+		size += checksumSize
+	}
+	data := make([]byte, headerSize, size)
+	copy(data, valueMagic)
+	data[len(valueMagic)] = valueVersion
+	data[len(valueMagic)+1] = flags
+	if expire != 0 {
+		data = data[:headerSize+8]
+		binary.BigEndian.PutUint64(data[headerSize:], uint64(expire))
+	}
+	data = append(data, body...)
+	if s.checksum {
+		n := len(data)
+		data = data[:n+checksumSize]
+		binary.BigEndian.PutUint32(data[n:], crc32.ChecksumIEEE(data[:n]))
+	}
+	return data, nil
+}
+
+// decode unframes raw, the returned val may share memory with raw
+func (s *Store) decode(raw []byte) (val []byte, expire int64, err error) {
+	flags, expire, val, err := frame(raw)
+	if err != nil {
+		return nil, 0, err
+	}
+	if flags&flagEncrypted != 0 {
+		if s.aead == nil {
+			return nil, 0, ErrNoEncryption
+		}
+		n := s.aead.NonceSize()
+		if len(val) < n {
+			return nil, 0, ErrDecrypt
+		}
+		if val, err = s.aead.Open(nil, val[:n], val[n:], nil); err != nil {
+			return nil, 0, ErrDecrypt
+		}
+	}
+	if flags&flagCompressed != 0 {
+		if s.compressor == nil {
+			return nil, 0, ErrNoCompression
+		}
+		if val, err = s.compressor.Decompress(val); err != nil {
+			return nil, 0, err
+		}
+	}
+	return val, expire, nil
+}
+
+// expiry reads the expiry from the header of raw alone, without verifying a checksum,
+// for readers that never look at the body
+func expiry(raw []byte) (expire int64, err error) {
+	if !bytes.HasPrefix(raw, []byte(valueMagic)) {
+		return 0, nil
+	}
+	if len(raw) < headerSize || raw[len(valueMagic)] != valueVersion {
+		return 0, ErrInvalidValue
+	}
+	if raw[len(valueMagic)+1]&flagExpire == 0 {
+		return 0, nil
+	}
+	if len(raw) < headerSize+8 {
+		return 0, ErrInvalidValue
+	}
+	return int64(binary.BigEndian.Uint64(raw[headerSize:])), nil
+}
+
+// frame splits raw into its flags, expiry and body without transforming the body,
+// a checksum is verified here so every reader of the frame sees a mismatch
+func frame(raw []byte) (flags byte, expire int64, body []byte, err error) {
+	if !bytes.HasPrefix(raw, []byte(valueMagic)) {
+		return 0, 0, raw, nil
+	}
+	if len(raw) < headerSize || raw[len(valueMagic)] != valueVersion {
+		return 0, 0, nil, ErrInvalidValue
+	}
+
+	flags, body = raw[len(valueMagic)+1], raw[headerSize:]
+	if flags&flagChecksum != 0 {
+		if len(body) < checksumSize {
+			return 0, 0, nil, ErrInvalidValue
+		}
+		n := len(raw) - checksumSize
+		if crc32.ChecksumIEEE(raw[:n]) != binary.BigEndian.Uint32(raw[n:]) {
+			return 0, 0, nil, ErrChecksumMismatch
+		}
+		body = body[:len(body)-checksumSize]
+	}
+	if flags&flagExpire != 0 {
+		if len(body) < 8 {
+			return 0, 0, nil, ErrInvalidValue
+		}
+		expire, body = int64(binary.BigEndian.Uint64(body)), body[8:]
+	}
+	return flags, expire, body, nil
+}
+
+// value decodes raw into a copy, ok is false when raw is missing or expired at now
+func (s *Store) value(raw []byte, now int64) (val []byte, ok bool, err error) {
+	if raw == nil {
+		return nil, false, nil
+	}
+	val, expire, err := s.decode(raw)
+	if err != nil || expired(expire, now) {
+		return nil, false, err
+	}
+	return clone(val), true, nil
+}
+
+// get reads the val at key, see value
+func (s *Store) get(b *bolt.Bucket, key []byte, now int64) ([]byte, bool, error) {
+	return s.value(b.Get(key), now)
+}
+
+func expired(expire, now int64) bool {
+	return expire != 0 && expire <= now
+}
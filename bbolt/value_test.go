@@ -0,0 +1,146 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// reopen closes s and opens its file again with opts
+func reopen(t *testing.T, s *Store, opts ...Option) *Store {
+	t.Helper()
+	path := s.path
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStore(path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestCompression(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gzip.db")
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bucket := []byte("b")
+	large := bytes.Repeat([]byte("compress me "), 1000)
+	mustSave(t, s, "b", "legacy", string(large))
+
+	s = reopen(t, s, WithCompression(Gzip))
+	if err := s.Save(bucket, []byte("new"), large); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"legacy", "new"} {
+		if val, err := s.Get(bucket, []byte(key)); err != nil || !bytes.Equal(val, large) {
+			t.Fatalf("Get(%s) = %d bytes, %v", key, len(val), err)
+		}
+	}
+	err = s.View(func(tx *Tx) error {
+		if raw := tx.tx.Bucket(bucket).Get([]byte("new")); len(raw) >= len(large) {
+			t.Errorf("stored %d bytes for a %d byte val", len(raw), len(large))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s = reopen(t, s)
+	if _, err := s.Get(bucket, []byte("new")); !errors.Is(err, ErrNoCompression) {
+		t.Fatalf("Get without a compressor = %v, want ErrNoCompression", err)
+	}
+}
+
+func TestEncryption(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	s := newTestStore(t, WithEncryption(key))
+	bucket := []byte("b")
+	mustSave(t, s, "b", "k", "secret")
+	if val, err := s.Get(bucket, []byte("k")); err != nil || string(val) != "secret" {
+		t.Fatalf("Get = %q, %v", val, err)
+	}
+	err := s.View(func(tx *Tx) error {
+		if raw := tx.tx.Bucket(bucket).Get([]byte("k")); bytes.Contains(raw, []byte("secret")) {
+			t.Error("val stored in the clear")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.Incr(bucket, []byte("n")); err != nil || n != 1 {
+		t.Fatalf("Incr = %d, %v, want 1", n, err)
+	}
+
+	s = reopen(t, s, WithEncryption(bytes.Repeat([]byte{8}, 32)))
+	if _, err := s.Get(bucket, []byte("k")); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("Get with the wrong key = %v, want ErrDecrypt", err)
+	}
+	if n, err := s.Incr(bucket, []byte("n")); err != nil || n != 2 {
+		t.Fatalf("Incr with another key = %d, %v, want 2", n, err)
+	}
+}
+
+func TestValueLen(t *testing.T) {
+	for name, opts := range map[string][]Option{"plain": nil, "compressed": {WithCompression(Gzip)}} {
+		s := newTestStore(t, opts...)
+		bucket := []byte("b")
+		large := bytes.Repeat([]byte("a"), 4096)
+		mustSave(t, s, "b", "empty", "")
+		mustSave(t, s, "b", "full", string(large))
+		if err := s.SaveWithTTL(bucket, []byte("ttl"), []byte("12345"), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveWithTTL(bucket, []byte("expired"), []byte("12345"), time.Nanosecond); err != nil {
+			t.Fatal(err)
+		}
+		for key, want := range map[string]int{"empty": 0, "full": len(large), "ttl": 5} {
+			if n, err := s.ValueLen(bucket, []byte(key)); err != nil || n != want {
+				t.Errorf("%s: ValueLen(%s) = %d, %v, want %d", name, key, n, err, want)
+			}
+		}
+		for _, key := range []string{"absent", "expired"} {
+			if _, err := s.ValueLen(bucket, []byte(key)); !errors.Is(err, ErrNotfound) {
+				t.Errorf("%s: ValueLen(%s) = %v, want ErrNotfound", name, key, err)
+			}
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crc.db")
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bucket := []byte("b")
+	mustSave(t, s, "b", "legacy", "old")
+
+	s = reopen(t, s, WithChecksum())
+	mustSave(t, s, "b", "k", "checked")
+	for key, want := range map[string]string{"legacy": "old", "k": "checked"} {
+		if val, err := s.Get(bucket, []byte(key)); err != nil || string(val) != want {
+			t.Fatalf("Get(%s) = %q, %v, want %q", key, val, err, want)
+		}
+	}
+
+	err = s.Update(func(tx *Tx) error {
+		b := tx.tx.Bucket(bucket)
+		raw := clone(b.Get([]byte("k")))
+		raw[headerSize] ^= 1
+		return b.Put([]byte("k"), raw)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(bucket, []byte("k")); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Get of a corrupted val = %v, want ErrChecksumMismatch", err)
+	}
+}
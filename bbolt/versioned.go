@@ -0,0 +1,123 @@
+package db
+
+import (
+	"bytes"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// historySuffix names the top-level bucket next to bucket that a VersionedStore keeps old vals
+// in, one nested bucket per key keyed by Uint64Key of its sequence so key order is version order
+const historySuffix = "\x00history"
+
+// VersionedStore keeps the last vals of each key in bucket, the current one stays at the key
+// so it reads like any other and bucket holds nothing else. Older ones move to the bucket
+// named bucket followed by "\x00history", which ListBuckets, Summary and ForEachBucket leave
+// out and which DeleteBucket, RenameBucket, Truncate and ReplaceBucket handle along with bucket.
+type VersionedStore struct {
+	s      *Store
+	bucket []byte
+	keep   int
+}
+
+// Versioned returns the versioned view of bucket keeping up to keep old vals per key
+func (s *Store) Versioned(bucket []byte, keep int) *VersionedStore {
+	return &VersionedStore{s: s, bucket: bucket, keep: keep}
+}
+
+// Put val at key and moves the val it replaces into the history, dropping the oldest beyond keep
+func (v *VersionedStore) Put(key, val []byte) error {
+	return v.s.Update(func(tx *Tx) error {
+		b, err := tx.bucket(v.bucket)
+		if err != nil {
+			return err
+		}
+		old, ok, err := tx.s.get(b, key, tx.now)
+		if err != nil {
+			return err
+		}
+		if ok && v.keep > 0 {
+			hb, err := tx.tx.CreateBucketIfNotExists(historyName(v.bucket))
+			if err != nil {
+				return err
+			}
+			kb, err := hb.CreateBucketIfNotExists(key)
+			if err != nil {
+				return err
+			}
+			id, err := kb.NextSequence()
+			if err != nil {
+				return err
+			}
+			if err = tx.put(nil, kb, Uint64Key(id), old, 0); err != nil {
+				return err
+			}
+
+			// everything before the keep newest versions goes
+			c := kb.Cursor()
+			k, _ := c.Last()
+			for i := 1; i < v.keep && k != nil; i++ {
+				k, _ = c.Prev()
+			}
+			if k != nil {
+				if _, err = tx.deleteFrom(nil, c, nil, func(key []byte) bool { return bytes.Compare(key, k) < 0 }); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.put(v.bucket, b, key, val, 0)
+	})
+}
+
+// Get the current val of key
+func (v *VersionedStore) Get(key []byte) ([]byte, error) {
+	return v.s.Get(v.bucket, key)
+}
+
+// History returns the old vals of key newest first, the current val is not included
+func (v *VersionedStore) History(key []byte) (vals [][]byte, err error) {
+	vals = [][]byte{}
+	err = v.s.View(func(tx *Tx) error {
+		if _, err := tx.bucket(v.bucket); err != nil {
+			return err
+		}
+		hb := tx.tx.Bucket(historyName(v.bucket))
+		if hb == nil {
+			return nil
+		}
+		kb := hb.Bucket(key)
+		if kb == nil {
+			return nil
+		}
+		c := kb.Cursor()
+		for k, raw := c.Last(); k != nil; k, raw = c.Prev() {
+			val, ok, err := tx.s.value(raw, tx.now)
+			if err != nil {
+				return err
+			}
+			if ok {
+				vals = append(vals, val)
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// historyName returns the name of the bucket holding the old vals of bucket
+func historyName(bucket []byte) []byte {
+	return append(bucket[:len(bucket):len(bucket)], historySuffix...)
+}
+
+// isHistory reports whether the top-level bucket name holds the old vals of a VersionedStore
+func isHistory(name []byte) bool {
+	return bytes.HasSuffix(name, []byte(historySuffix))
+}
+
+// dropHistory deletes the old vals kept for bucket, if any
+func (tx *Tx) dropHistory(bucket []byte) error {
+	if err := tx.tx.DeleteBucket(historyName(bucket)); err != bolt.ErrBucketNotFound {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestVersionedTrim(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	v := s.Versioned(bucket, 3)
+	for i := 1; i <= 6; i++ {
+		if err := v.Put([]byte("k"), []byte(fmt.Sprintf("v%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if val, err := v.Get([]byte("k")); err != nil || string(val) != "v6" {
+		t.Fatalf("Get = %q, %v, want v6", val, err)
+	}
+	history, err := v.History([]byte("k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprintf("%s", history); got != "[v5 v4 v3]" {
+		t.Fatalf("History = %s, want [v5 v4 v3]", got)
+	}
+	if n, err := s.Count(bucket); err != nil || n != 1 {
+		t.Fatalf("Count = %d, %v, want only the current key", n, err)
+	}
+}
+
+func TestVersionedHistoryKeyName(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	v := s.Versioned(bucket, 2)
+	key := []byte(historySuffix)
+	for _, val := range []string{"a", "b"} {
+		if err := v.Put(key, []byte(val)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := v.Put([]byte("other"), []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if history, err := v.History(key); err != nil || len(history) != 1 || string(history[0]) != "a" {
+		t.Fatalf("History = %q, %v", history, err)
+	}
+}
+
+func TestVersionedHistoryFollowsBucket(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	for _, val := range []string{"v1", "v2", "v3"} {
+		if err := s.Versioned(bucket, 5).Put([]byte("k"), []byte(val)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names, err := s.ListBuckets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprintf("%s", names); got != "[b]" {
+		t.Fatalf("ListBuckets = %q, want only b", got)
+	}
+	if sum, err := s.Summary(); err != nil || sum.BucketCount != 1 || sum.TotalKeys != 1 {
+		t.Fatalf("Summary = %+v, %v, want one bucket with one key", sum, err)
+	}
+	n := 0
+	if err := s.ForEachBucket(func([]byte, *BucketView) error { n++; return nil }); err != nil || n != 1 {
+		t.Fatalf("ForEachBucket visited %d, %v, want 1", n, err)
+	}
+
+	if err := s.RenameBucket(bucket, []byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	history, err := s.Versioned([]byte("c"), 5).History([]byte("k"))
+	if err != nil || fmt.Sprintf("%s", history) != "[v2 v1]" {
+		t.Fatalf("History after RenameBucket = %s, %v, want [v2 v1]", history, err)
+	}
+
+	if err := s.DeleteBucket([]byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	err = s.View(func(tx *Tx) error {
+		for _, name := range []string{"b", "c"} {
+			if tx.tx.Bucket(historyName([]byte(name))) != nil {
+				t.Fatalf("history of %s left behind", name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
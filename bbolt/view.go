@@ -0,0 +1,38 @@
+package db
+
+import (
+	bolt "go.etcd.io/bbolt"
+)
+
+// BucketView is read access to one bucket inside ForEachBucket, it must not be used after the callback returns
+type BucketView struct {
+	tx *Tx
+	b  *bolt.Bucket
+}
+
+// ForEachBucket calls fn for every top-level bucket in name order within a single read transaction,
+// the history of a VersionedStore left out. An error from fn stops the iteration and is returned.
+func (s *Store) ForEachBucket(fn func(name []byte, v *BucketView) error) error {
+	return s.View(func(tx *Tx) error {
+		return tx.tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if isHistory(name) {
+				return nil
+			}
+			return fn(clone(name), &BucketView{tx: tx, b: b})
+		})
+	})
+}
+
+// Get val by key from the bucket
+func (v *BucketView) Get(key []byte) ([]byte, error) {
+	val, ok, err := v.tx.s.get(v.b, key, v.tx.now)
+	if err == nil && !ok {
+		err = ErrNotfound
+	}
+	return val, err
+}
+
+// Scan every key in the bucket like Store.Scan
+func (v *BucketView) Scan(next func(key, val []byte) bool) error {
+	return ignoreStop(v.tx.scan(v.b, next))
+}
@@ -0,0 +1,33 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestForEachBucket(t *testing.T) {
+	s := newTestStore(t)
+	for i, bucket := range []string{"a", "b", "c"} {
+		for j := 0; j <= i; j++ {
+			mustSave(t, s, bucket, fmt.Sprint(j), "v")
+		}
+	}
+	var counts []string
+	err := s.ForEachBucket(func(name []byte, v *BucketView) error {
+		n := 0
+		if err := v.Scan(func(key, val []byte) bool {
+			n++
+			return true
+		}); err != nil {
+			return err
+		}
+		counts = append(counts, fmt.Sprintf("%s=%d", name, n))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(counts); got != "[a=1 b=2 c=3]" {
+		t.Fatalf("counts = %s, want [a=1 b=2 c=3]", got)
+	}
+}
@@ -0,0 +1,76 @@
+package db
+
+// Op is the kind of change an Event reports
+type Op uint8
+
+const (
+	OpPut Op = iota + 1
+	OpDelete
+)
+
+// watchBuffer is how many events a watcher may lag behind before new ones are dropped
+const watchBuffer = 64
+
+// Event is a committed change to a key, Val is nil for deletes
+type Event struct {
+	Op     Op
+	Bucket []byte
+	Key    []byte
+	Val    []byte
+}
+
+// Watch returns a channel of the changes committed to bucket and a func that
+// unsubscribes and closes it. Events are sent in commit order, but a watcher
+// that falls more than watchBuffer events behind misses the newer ones.
+// Close closes the channel too.
+func (s *Store) Watch(bucket []byte) (<-chan Event, func()) {
+	ch := make(chan Event, watchBuffer)
+	name := string(bucket)
+
+	s.mu.Lock()
+	if s.closed() {
+		s.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if s.watchers == nil {
+		s.watchers = make(map[string][]chan Event)
+	}
+	s.watchers[name] = append(s.watchers[name], ch)
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		chs := s.watchers[name]
+		for i, c := range chs {
+			if c == ch {
+				s.watchers[name] = append(chs[:i:i], chs[i+1:]...)
+				close(ch)
+				break
+			}
+		}
+		if len(s.watchers[name]) == 0 {
+			delete(s.watchers, name)
+		}
+	}
+}
+
+func (s *Store) watching(bucket []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.watchers[string(bucket)]) > 0
+}
+
+func (s *Store) publish(events []Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range events {
+		for _, ch := range s.watchers[string(e.Bucket)] {
+			select {
+			case ch <- e:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatal("events closed")
+		}
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event")
+	}
+	return Event{}
+}
+
+func TestWatch(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	if err := s.CreateBuckets(bucket, []byte("other")); err != nil {
+		t.Fatal(err)
+	}
+	events, unwatch := s.Watch(bucket)
+	mustSave(t, s, "other", "k", "ignored")
+	mustSave(t, s, "b", "k", "v")
+	if err := s.Delete(bucket, []byte("k")); err != nil {
+		t.Fatal(err)
+	}
+
+	if ev := nextEvent(t, events); ev.Op != OpPut || string(ev.Bucket) != "b" || string(ev.Key) != "k" || string(ev.Val) != "v" {
+		t.Fatalf("first event = %+v, want the put", ev)
+	}
+	if ev := nextEvent(t, events); ev.Op != OpDelete || string(ev.Key) != "k" || ev.Val != nil {
+		t.Fatalf("second event = %+v, want the delete", ev)
+	}
+	unwatch()
+	if _, ok := <-events; ok {
+		t.Fatal("events still open after unwatch")
+	}
+}
+
+func TestWatchRolledBack(t *testing.T) {
+	s := newTestStore(t)
+	bucket := []byte("b")
+	if err := s.CreateBucketIfNotExist(bucket); err != nil {
+		t.Fatal(err)
+	}
+	events, unwatch := s.Watch(bucket)
+	defer unwatch()
+	_ = s.Update(func(tx *Tx) error {
+		_ = tx.Save(bucket, []byte("k"), []byte("v"))
+		return ErrNotfound
+	})
+	mustSave(t, s, "b", "after", "v")
+	if ev := nextEvent(t, events); string(ev.Key) != "after" {
+		t.Fatalf("event = %+v, want only the committed save", ev)
+	}
+}
@@ -1,5 +1,7 @@
 module github.com/chinx/kvass
 
-go 1.16
+go 1.23
 
 require go.etcd.io/bbolt v1.3.6
+
+require golang.org/x/sys v0.0.0-20200923182605-d9f96fdee20d // indirect